@@ -0,0 +1,25 @@
+package ezbson
+
+// Binary subtypes, as defined by the BSON spec.
+const (
+	BinarySubtypeGeneric     byte = 0x00
+	BinarySubtypeFunction    byte = 0x01
+	BinarySubtypeBinaryOld   byte = 0x02 // Deprecated: the data is prefixed by its (int32) size.
+	BinarySubtypeUUIDOld     byte = 0x03 // Deprecated: use BinarySubtypeUUID.
+	BinarySubtypeUUID        byte = 0x04
+	BinarySubtypeMD5         byte = 0x05
+	BinarySubtypeEncrypted   byte = 0x06
+	BinarySubtypeColumn      byte = 0x07
+	BinarySubtypeSensitive   byte = 0x08
+	BinarySubtypeUserDefined byte = 0x80 // User-defined subtypes are in the range [0x80, 0xff].
+)
+
+// Binary is the BSON binary type (etype 5), along with its subtype.
+//
+// A []byte is serialized as binary with the generic subtype (0), so Binary is only needed for other subtypes.
+// For the deprecated subtype 2 (BinarySubtypeBinaryOld), Data holds the payload without the inner size prefix
+// (ezbson adds and removes it).
+type Binary struct {
+	Subtype byte
+	Data    []byte
+}
@@ -0,0 +1,97 @@
+package ezbson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinarySubtypeMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x0f, 0x00, 0x00, 0x00, // total document size
+		0x05, // etype-binary
+		'b', 0x00,
+		0x02, 0x00, 0x00, 0x00, // size
+		0x80, // subtype (user-defined)
+		'a', 'b',
+		0x00,
+	}
+
+	bin := Binary{Subtype: BinarySubtypeUserDefined, Data: []byte("ab")}
+
+	marshalled, err := Marshal(map[string]any{"b": bin})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"b": bin}, asMap)
+
+	asBinary := make(map[string]Binary)
+	if err := Unmarshal(kMarshalled, &asBinary); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]Binary{"b": bin}, asBinary)
+
+	asBytes := make(map[string][]byte)
+	if err := Unmarshal(kMarshalled, &asBytes); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string][]byte{"b": []byte("ab")}, asBytes)
+}
+
+func TestBinaryGenericSubtypeIntoAny(t *testing.T) {
+	marshalled, err := Marshal(map[string]any{"b": Binary{Subtype: BinarySubtypeGeneric, Data: []byte("ab")}})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(marshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"b": []byte("ab")}, asMap)
+}
+
+func TestBinaryOldSubtype(t *testing.T) {
+	kMarshalled := []byte{
+		0x13, 0x00, 0x00, 0x00, // total document size
+		0x05, // etype-binary
+		'b', 0x00,
+		0x06, 0x00, 0x00, 0x00, // size
+		0x02,                   // subtype (binary old)
+		0x02, 0x00, 0x00, 0x00, // inner size
+		'a', 'b',
+		0x00,
+	}
+
+	bin := Binary{Subtype: BinarySubtypeBinaryOld, Data: []byte("ab")}
+
+	marshalled, err := Marshal(map[string]any{"b": bin})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"b": bin}, asMap)
+
+	badInnerSize := []byte{
+		0x13, 0x00, 0x00, 0x00, // total document size
+		0x05, // etype-binary
+		'b', 0x00,
+		0x06, 0x00, 0x00, 0x00, // size
+		0x02,                   // subtype (binary old)
+		0x03, 0x00, 0x00, 0x00, // inner size (wrong, should be 2)
+		'a', 'b',
+		0x00,
+	}
+	assert.NotNil(t, Unmarshal(badInnerSize, &asMap))
+}
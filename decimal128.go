@@ -0,0 +1,379 @@
+package ezbson
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal128 is the BSON decimal128 type (etype 19): an IEEE 754-2008 128-bit decimal floating point number,
+// using the binary integer decimal (BID) encoding.
+//
+// A Decimal128 holds a sign, a coefficient of up to 34 decimal digits, and an exponent in the range [-6176, 6111],
+// or one of the special values NaN, +Infinity and -Infinity.
+//
+// The zero value is 0E-6176 (zero, with the smallest exponent), which is numerically zero, but is not the same
+// Decimal128 as ParseDecimal128("0") (whose exponent is 0). Use IsZero and Equal rather than comparing Decimal128s with ==.
+type Decimal128 struct {
+	h uint64 // high 64 bits (sign, combination field, and the top of the coefficient)
+	l uint64 // low 64 bits (the bottom of the coefficient)
+}
+
+const (
+	kDecimal128MaxDigits    = 34
+	kDecimal128ExpBias      = 6176
+	kDecimal128MinExp       = -6176
+	kDecimal128MaxExp       = 6111
+	kDecimal128ExpMask      = 0x3fff
+	kDecimal128SignBit      = uint64(1) << 63
+	kDecimal128InfBits      = uint64(0x1e) << 58 // 0 11110 ...
+	kDecimal128NaNBits      = uint64(0x1f) << 58 // 0 11111 ...
+	kDecimal128SpecialMask  = uint64(0x1f) << 58
+	kDecimal128BigFloatPrec = 128
+)
+
+var (
+	decimal128MaxCoefficient = new(big.Int).Sub(new(big.Int).Exp(big.NewInt(10), big.NewInt(kDecimal128MaxDigits), nil), big.NewInt(1))
+	bigTen                   = big.NewInt(10)
+)
+
+// NewDecimal128 creates a Decimal128 from its high and low 64 bits (as stored in BSON).
+func NewDecimal128(h, l uint64) Decimal128 {
+	return Decimal128{h: h, l: l}
+}
+
+// Decimal128NaN returns a (quiet) NaN.
+func Decimal128NaN() Decimal128 {
+	return Decimal128{h: kDecimal128NaNBits}
+}
+
+// Decimal128Inf returns +Infinity if sign >= 0, and -Infinity if sign < 0.
+func Decimal128Inf(sign int) Decimal128 {
+	if sign < 0 {
+		return Decimal128{h: kDecimal128SignBit | kDecimal128InfBits}
+	}
+	return Decimal128{h: kDecimal128InfBits}
+}
+
+// GetBytes returns the high and low 64 bits of d.
+func (d Decimal128) GetBytes() (h, l uint64) {
+	return d.h, d.l
+}
+
+// IsNaN reports whether d is a NaN (quiet or signaling).
+func (d Decimal128) IsNaN() bool {
+	return d.h&kDecimal128SpecialMask == kDecimal128NaNBits
+}
+
+// IsInf returns +1 if d is +Infinity, -1 if d is -Infinity, and 0 otherwise.
+func (d Decimal128) IsInf() int {
+	if d.h&kDecimal128SpecialMask != kDecimal128InfBits {
+		return 0
+	}
+	if d.h&kDecimal128SignBit != 0 {
+		return -1
+	}
+	return 1
+}
+
+// IsZero reports whether d is zero, with any exponent and sign (e.g. "0", "-0", "0E+3", and the zero value).
+// Like time.Time, a zero Decimal128 is omitted by the omitempty tag option.
+func (d Decimal128) IsZero() bool {
+	if d.IsNaN() || d.IsInf() != 0 {
+		return false
+	}
+
+	coefficient, _ := d.unpack()
+	return coefficient.Sign() == 0
+}
+
+// Equal reports whether d and other are numerically equal, regardless of their exponents
+// (e.g. "1.5" equals "1.50", and "0" equals "-0E+3"). Like floating point NaNs, a NaN is not equal to anything.
+func (d Decimal128) Equal(other Decimal128) bool {
+	if d.IsNaN() || other.IsNaN() {
+		return false
+	}
+	if d.IsInf() != 0 || other.IsInf() != 0 {
+		return d.IsInf() == other.IsInf()
+	}
+
+	coefficient, exp, _ := d.BigInt()
+	otherCoefficient, otherExp, _ := other.BigInt()
+
+	// Scale the coefficient with the bigger exponent, so both have the smaller one
+	if exp > otherExp {
+		coefficient.Mul(coefficient, new(big.Int).Exp(bigTen, big.NewInt(int64(exp-otherExp)), nil))
+	} else {
+		otherCoefficient.Mul(otherCoefficient, new(big.Int).Exp(bigTen, big.NewInt(int64(otherExp-exp)), nil))
+	}
+
+	return coefficient.Cmp(otherCoefficient) == 0
+}
+
+// IsNegative reports whether the sign bit of d is set (this includes -0 and -Infinity).
+func (d Decimal128) IsNegative() bool {
+	return d.h&kDecimal128SignBit != 0
+}
+
+// BigInt returns the signed coefficient and the exponent of d, so that d == coefficient * 10^exponent.
+// An error is returned for NaN and Infinity.
+//
+// Note that -0 cannot be represented by a *big.Int, so the sign of a negative zero is lost (see IsNegative).
+func (d Decimal128) BigInt() (*big.Int, int, error) {
+	if d.IsNaN() {
+		return nil, 0, fmt.Errorf("cannot convert NaN to big.Int")
+	}
+	if d.IsInf() != 0 {
+		return nil, 0, fmt.Errorf("cannot convert Infinity to big.Int")
+	}
+
+	coefficient, exp := d.unpack()
+	if d.IsNegative() {
+		coefficient.Neg(coefficient)
+	}
+
+	return coefficient, exp, nil
+}
+
+// BigFloat returns d as a *big.Float. NaN returns an error; Infinity is returned as a big.Float infinity.
+//
+// Values with a non-negative exponent are integers, and are converted exactly.
+// Values with a negative exponent are converted with a precision of 128 bits, rounded to nearest even.
+func (d Decimal128) BigFloat() (*big.Float, error) {
+	if d.IsNaN() {
+		return nil, fmt.Errorf("cannot convert NaN to big.Float")
+	}
+	if inf := d.IsInf(); inf != 0 {
+		return new(big.Float).SetInf(inf < 0), nil
+	}
+
+	coefficient, exp := d.unpack()
+
+	var f *big.Float
+	if exp >= 0 {
+		scale := new(big.Int).Exp(bigTen, big.NewInt(int64(exp)), nil)
+		f = new(big.Float).SetInt(coefficient.Mul(coefficient, scale))
+	} else {
+		scale := new(big.Int).Exp(bigTen, big.NewInt(int64(-exp)), nil)
+		f = new(big.Float).SetPrec(kDecimal128BigFloatPrec).SetRat(new(big.Rat).SetFrac(coefficient, scale))
+	}
+
+	if d.IsNegative() {
+		f.Neg(f) // Also handles -0 (big.Float has a signed zero)
+	}
+
+	return f, nil
+}
+
+// Decimal128FromBigInt returns coefficient * 10^exp as a Decimal128.
+//
+// An error is returned if the value cannot be represented exactly
+// (i.e. it requires more than 34 significant digits, or the exponent is out of range).
+func Decimal128FromBigInt(coefficient *big.Int, exp int) (Decimal128, error) {
+	negative := coefficient.Sign() < 0
+	return packDecimal128(negative, new(big.Int).Abs(coefficient), exp)
+}
+
+// Decimal128FromBigFloat converts f to a Decimal128, rounding it to 34 significant digits.
+// If f is converted exactly, trailing zeros are dropped (e.g. 0.5 is converted to "0.5", and 0 to "0").
+// Infinities are converted to Decimal128 infinities.
+func Decimal128FromBigFloat(f *big.Float) (Decimal128, error) {
+	if f.IsInf() {
+		return Decimal128Inf(f.Sign()), nil
+	}
+
+	text := f.Text('e', kDecimal128MaxDigits-1) // e.g. "5.000000000000000000000000000000000e-01"
+
+	exact, _ := f.Rat(nil)
+	if rounded, ok := new(big.Rat).SetString(text); ok && rounded.Cmp(exact) == 0 {
+		mantissa, exp, _ := strings.Cut(text, "e")
+		text = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".") + "e" + exp
+	}
+
+	return ParseDecimal128(text)
+}
+
+// ParseDecimal128 parses a decimal string (e.g. "-12.345", "1.5E-3", "Infinity", "NaN") into a Decimal128.
+//
+// The significant digits and the exponent are preserved exactly (so "1.50" and "1.5" produce different values).
+// An error is returned if the value cannot be represented exactly
+// (i.e. it requires more than 34 significant digits, or the exponent is out of range).
+func ParseDecimal128(s string) (Decimal128, error) {
+	orig := s
+
+	negative := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	switch strings.ToLower(s) {
+	case "inf", "infinity":
+		if negative {
+			return Decimal128Inf(-1), nil
+		}
+		return Decimal128Inf(1), nil
+	case "nan":
+		return Decimal128NaN(), nil
+	}
+
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		parsedExp, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Decimal128{}, fmt.Errorf("invalid decimal128 exponent (%v)", orig)
+		}
+		exp = parsedExp
+		s = s[:i]
+	}
+
+	digits := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits = s[:i] + s[i+1:]
+		exp -= len(s) - i - 1
+	}
+
+	if len(digits) == 0 {
+		return Decimal128{}, fmt.Errorf("invalid decimal128 (%v)", orig)
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return Decimal128{}, fmt.Errorf("invalid decimal128 (%v)", orig)
+		}
+	}
+
+	coefficient, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal128{}, fmt.Errorf("invalid decimal128 (%v)", orig)
+	}
+
+	d, err := packDecimal128(negative, coefficient, exp)
+	if err != nil {
+		return Decimal128{}, fmt.Errorf("%w (%v)", err, orig)
+	}
+
+	return d, nil
+}
+
+// String formats d using the IEEE 754 to-scientific-string conversion
+// (the same format used by MongoDB, e.g. "12.345", "1.5E-10", "-Infinity").
+func (d Decimal128) String() string {
+	if d.IsNaN() {
+		return "NaN"
+	}
+	if inf := d.IsInf(); inf != 0 {
+		if inf < 0 {
+			return "-Infinity"
+		}
+		return "Infinity"
+	}
+
+	coefficient, exp := d.unpack()
+	digits := coefficient.String()
+	adjustedExp := exp + len(digits) - 1
+
+	var sb strings.Builder
+	if d.IsNegative() {
+		sb.WriteByte('-')
+	}
+
+	switch {
+	case exp <= 0 && adjustedExp >= -6:
+		// Plain notation
+		if exp == 0 {
+			sb.WriteString(digits)
+		} else if len(digits) > -exp {
+			sb.WriteString(digits[:len(digits)+exp])
+			sb.WriteByte('.')
+			sb.WriteString(digits[len(digits)+exp:])
+		} else {
+			sb.WriteString("0.")
+			sb.WriteString(strings.Repeat("0", -exp-len(digits)))
+			sb.WriteString(digits)
+		}
+	default:
+		// Scientific notation
+		sb.WriteByte(digits[0])
+		if len(digits) > 1 {
+			sb.WriteByte('.')
+			sb.WriteString(digits[1:])
+		}
+		sb.WriteByte('E')
+		if adjustedExp >= 0 {
+			sb.WriteByte('+')
+		}
+		sb.WriteString(strconv.Itoa(adjustedExp))
+	}
+
+	return sb.String()
+}
+
+// unpack returns the (unsigned) coefficient and exponent of a finite d.
+// Non-canonical coefficients (larger than 34 digits) are treated as zero, as required by the spec.
+func (d Decimal128) unpack() (*big.Int, int) {
+	var biasedExp uint64
+	coefficient := new(big.Int)
+
+	if (d.h>>61)&0x3 == 0x3 {
+		// The coefficient would be at least 2^113, which is always non-canonical.
+		biasedExp = (d.h >> 47) & kDecimal128ExpMask
+	} else {
+		biasedExp = (d.h >> 49) & kDecimal128ExpMask
+
+		coefficient.SetUint64(d.h & (1<<49 - 1))
+		coefficient.Lsh(coefficient, 64)
+		coefficient.Or(coefficient, new(big.Int).SetUint64(d.l))
+
+		if coefficient.Cmp(decimal128MaxCoefficient) > 0 {
+			coefficient.SetInt64(0)
+		}
+	}
+
+	return coefficient, int(biasedExp) - kDecimal128ExpBias
+}
+
+// packDecimal128 encodes an (unsigned) coefficient and an exponent, adjusting them without losing precision
+// when they are out of range (e.g. 1230E-6177 -> 123E-6176).
+// coefficient is modified.
+func packDecimal128(negative bool, coefficient *big.Int, exp int) (Decimal128, error) {
+	remainder := new(big.Int)
+	quotient := new(big.Int)
+
+	// Too many digits, or an exponent that is too small: drop trailing zeros.
+	for coefficient.Cmp(decimal128MaxCoefficient) > 0 || (exp < kDecimal128MinExp && coefficient.Sign() != 0) {
+		quotient.QuoRem(coefficient, bigTen, remainder)
+		if remainder.Sign() != 0 {
+			return Decimal128{}, fmt.Errorf("decimal128 cannot represent value exactly")
+		}
+		coefficient.Set(quotient)
+		exp++
+	}
+
+	// An exponent that is too big: add trailing zeros.
+	for exp > kDecimal128MaxExp && coefficient.Sign() != 0 {
+		coefficient.Mul(coefficient, bigTen)
+		if coefficient.Cmp(decimal128MaxCoefficient) > 0 {
+			return Decimal128{}, fmt.Errorf("decimal128 exponent overflow")
+		}
+		exp--
+	}
+
+	// Zero can have any exponent, so it is clamped.
+	if coefficient.Sign() == 0 {
+		exp = max(min(exp, kDecimal128MaxExp), kDecimal128MinExp)
+	}
+
+	var d Decimal128
+	if negative {
+		d.h |= kDecimal128SignBit
+	}
+	d.h |= uint64(exp+kDecimal128ExpBias) << 49
+
+	low := new(big.Int).And(coefficient, new(big.Int).SetUint64(^uint64(0)))
+	high := new(big.Int).Rsh(coefficient, 64)
+	d.l = low.Uint64()
+	d.h |= high.Uint64()
+
+	return d, nil
+}
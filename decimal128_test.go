@@ -0,0 +1,245 @@
+package ezbson
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecimal128ParseAndString(t *testing.T) {
+	// Test vectors are taken from the BSON corpus (https://github.com/mongodb/specifications/tree/master/source/bson-corpus)
+	tests := []struct {
+		input    string
+		h        uint64
+		l        uint64
+		expected string
+	}{
+		{"0", 0x3040000000000000, 0, "0"},
+		{"-0", 0xb040000000000000, 0, "-0"},
+		{"1", 0x3040000000000000, 1, "1"},
+		{"-1", 0xb040000000000000, 1, "-1"},
+		{"0.1", 0x303e000000000000, 1, "0.1"},
+		{"1E+3", 0x3046000000000000, 1, "1E+3"},
+		{"1e3", 0x3046000000000000, 1, "1E+3"},
+		{"1000", 0x3040000000000000, 1000, "1000"},
+		{"12.345", 0x303a000000000000, 12345, "12.345"},
+		{"1.50", 0x303c000000000000, 150, "1.50"},
+		{"0.000001234", 0x302e000000000000, 1234, "0.000001234"},
+		{"1.234E-7", 0x302c000000000000, 1234, "1.234E-7"},
+		{"Infinity", 0x7800000000000000, 0, "Infinity"},
+		{"-Infinity", 0xf800000000000000, 0, "-Infinity"},
+		{"inf", 0x7800000000000000, 0, "Infinity"},
+		{"NaN", 0x7c00000000000000, 0, "NaN"},
+		{"9.999999999999999999999999999999999E+6144", 0x5fffed09bead87c0, 0x378d8e63ffffffff, "9.999999999999999999999999999999999E+6144"},
+		{"1E-6176", 0x0000000000000000, 1, "1E-6176"},
+		{"1E+6144", 0x5ffe314dc6448d93, 0x38c15b0a00000000, "1.000000000000000000000000000000000E+6144"}, // clamped
+		{"0E+9000", 0x5ffe000000000000, 0, "0E+6111"},                                                    // clamped zero
+		{"10E-6177", 0x0000000000000000, 1, "1E-6176"},                                                   // trailing zero dropped
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			d, err := ParseDecimal128(test.input)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			h, l := d.GetBytes()
+			assert.Equal(t, test.h, h)
+			assert.Equal(t, test.l, l)
+			assert.Equal(t, test.expected, d.String())
+		})
+	}
+}
+
+func TestDecimal128ParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"-",
+		".",
+		"1.2.3",
+		"abc",
+		"1E",
+		"1Ex",
+		"1234567890123456789012345678901234567", // 37 significant digits
+		"1E+6200",
+		"1E-6200",
+	} {
+		_, err := ParseDecimal128(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestDecimal128NonCanonical(t *testing.T) {
+	// Coefficients larger than 34 digits are treated as zero.
+	d := NewDecimal128(0x6c10000000000000, 0)
+	assert.Equal(t, "0", d.String())
+
+	d = NewDecimal128(0x304fffffffffffff, 0xffffffffffffffff)
+	assert.Equal(t, "0E+7", d.String())
+}
+
+func TestDecimal128BigInt(t *testing.T) {
+	d, err := ParseDecimal128("-12.345")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	coefficient, exp, err := d.BigInt()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, big.NewInt(-12345), coefficient)
+	assert.Equal(t, -3, exp)
+
+	fromBigInt, err := Decimal128FromBigInt(coefficient, exp)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, d, fromBigInt)
+
+	tooBig, _ := new(big.Int).SetString("12345678901234567890123456789012345", 10)
+	_, err = Decimal128FromBigInt(tooBig, 0)
+	assert.NotNil(t, err)
+
+	_, _, err = Decimal128NaN().BigInt()
+	assert.NotNil(t, err)
+	_, _, err = Decimal128Inf(1).BigInt()
+	assert.NotNil(t, err)
+}
+
+func TestDecimal128BigFloat(t *testing.T) {
+	d, err := ParseDecimal128("1.5E+3")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	f, err := d.BigFloat()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 0, f.Cmp(big.NewFloat(1500)))
+
+	d, err = ParseDecimal128("-0.25")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	f, err = d.BigFloat()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 0, f.Cmp(big.NewFloat(-0.25)))
+
+	fromBigFloat, err := Decimal128FromBigFloat(big.NewFloat(-0.25))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "-0.25", fromBigFloat.String())
+
+	for _, test := range []struct {
+		f        *big.Float
+		expected string
+	}{
+		{big.NewFloat(0.5), "0.5"},
+		{big.NewFloat(0), "0"},
+		{new(big.Float).Neg(big.NewFloat(0)), "-0"},
+		{big.NewFloat(1500), "1.5E+3"},
+		{big.NewFloat(0.1), "0.1000000000000000055511151231257827"}, // Not exact, so it's rounded to 34 digits
+		{new(big.Float).SetPrec(200).Quo(big.NewFloat(1), big.NewFloat(3)), "0.3333333333333333333333333333333333"},
+	} {
+		fromBigFloat, err = Decimal128FromBigFloat(test.f)
+		if assert.Nil(t, err, test.expected) {
+			assert.Equal(t, test.expected, fromBigFloat.String())
+		}
+	}
+
+	f, err = Decimal128Inf(-1).BigFloat()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, f.IsInf())
+	assert.Equal(t, -1, f.Sign())
+
+	fromBigFloat, err = Decimal128FromBigFloat(f)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, -1, fromBigFloat.IsInf())
+
+	_, err = Decimal128NaN().BigFloat()
+	assert.NotNil(t, err)
+}
+
+func TestDecimal128IsZeroAndEqual(t *testing.T) {
+	parse := func(s string) Decimal128 {
+		d, err := ParseDecimal128(s)
+		if !assert.Nil(t, err, s) {
+			t.FailNow()
+		}
+		return d
+	}
+
+	assert.Equal(t, "0E-6176", Decimal128{}.String())
+	assert.NotEqual(t, parse("0"), Decimal128{})
+
+	for _, zero := range []Decimal128{{}, parse("0"), parse("-0"), parse("0E+3"), NewDecimal128(0x6c10000000000000, 0)} {
+		assert.True(t, zero.IsZero(), zero.String())
+		assert.True(t, zero.Equal(parse("0")), zero.String())
+	}
+	for _, nonZero := range []Decimal128{parse("1E-6176"), parse("-1"), Decimal128Inf(1), Decimal128NaN()} {
+		assert.False(t, nonZero.IsZero(), nonZero.String())
+	}
+
+	assert.True(t, parse("1.5").Equal(parse("1.50")))
+	assert.True(t, parse("1.5E+3").Equal(parse("1500")))
+	assert.True(t, parse("-1E+6111").Equal(parse("-1000E+6108")))
+	assert.False(t, parse("1.5").Equal(parse("-1.5")))
+	assert.False(t, parse("1.5").Equal(parse("1.51")))
+	assert.True(t, Decimal128Inf(-1).Equal(Decimal128Inf(-1)))
+	assert.False(t, Decimal128Inf(-1).Equal(Decimal128Inf(1)))
+	assert.False(t, Decimal128NaN().Equal(Decimal128NaN()))
+}
+
+func TestDecimal128MarshalUnmarshal(t *testing.T) {
+	d, err := ParseDecimal128("0.1")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	kMarshalled := []byte{
+		0x18, 0x00, 0x00, 0x00, // total document size
+		0x13, // etype-decimal128
+		'd', 0x00,
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3e, 0x30,
+		0x00,
+	}
+
+	marshalled, err := Marshal(map[string]any{"d": d})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"d": d}, asMap)
+
+	asStruct := struct {
+		D Decimal128
+	}{}
+	kMarshalled[5] = 'D'
+	if err := Unmarshal(kMarshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, d, asStruct.D)
+
+	wrongType := struct {
+		D float64
+	}{}
+	assert.NotNil(t, Unmarshal(kMarshalled, &wrongType))
+}
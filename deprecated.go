@@ -0,0 +1,21 @@
+package ezbson
+
+// Undefined is the deprecated BSON undefined type (etype 6).
+// It has no value (it is serialized as a zero-length element).
+//
+// Marshalling it requires Encoder.AllowDeprecatedTypes.
+type Undefined struct{}
+
+// DBPointer is the deprecated BSON DBPointer type (etype 12): a reference to a document (by ObjectID) in the collection Ref.
+//
+// Marshalling it requires Encoder.AllowDeprecatedTypes.
+type DBPointer struct {
+	Ref string
+	ID  ObjectID
+}
+
+// Symbol is the deprecated BSON symbol type (etype 14).
+// It is serialized like a string.
+//
+// Marshalling it requires Encoder.AllowDeprecatedTypes.
+type Symbol string
@@ -0,0 +1,86 @@
+package ezbson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecatedTypesMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x2b, 0x00, 0x00, 0x00, // total document size
+
+		0x0c, // etype-dbpointer
+		'p', 0x00,
+		0x05, 0x00, 0x00, 0x00,
+		'd', 'b', '.', 'c', 0x00,
+		0x5f, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x7a, 0x8b, 0x9c, 0x0d, 0x1e,
+
+		0x0e, // etype-symbol
+		's', 0x00,
+		0x04, 0x00, 0x00, 0x00,
+		's', 'y', 'm', 0x00,
+
+		0x06, // etype-undefined
+		'u', 0x00,
+
+		0x00,
+	}
+
+	doc := map[string]any{
+		"p": DBPointer{Ref: "db.c", ID: ObjectID{0x5f, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x7a, 0x8b, 0x9c, 0x0d, 0x1e}},
+		"s": Symbol("sym"),
+		"u": Undefined{},
+	}
+
+	for key, val := range doc {
+		_, err := Marshal(map[string]any{key: val})
+		assert.NotNil(t, err, key)
+	}
+
+	enc := Encoder{AllowDeprecatedTypes: true}
+	marshalled, err := enc.Marshal(doc)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, doc, asMap)
+}
+
+func TestDeprecatedTypesToModernTypes(t *testing.T) {
+	kMarshalled := []byte{
+		0x16, 0x00, 0x00, 0x00, // total document size
+
+		0x0e, // etype-symbol
+		'S', 0x00,
+		0x04, 0x00, 0x00, 0x00,
+		's', 'y', 'm', 0x00,
+
+		0x06, // etype-undefined
+		'U', 0x00,
+
+		0x06, // etype-undefined
+		'V', 0x00,
+
+		0x00,
+	}
+
+	num := int64(5)
+	actual := struct {
+		S string
+		U *int64
+		V Undefined
+	}{U: &num}
+
+	if err := Unmarshal(kMarshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "sym", actual.S)
+	assert.Nil(t, actual.U)
+	assert.Equal(t, Undefined{}, actual.V)
+}
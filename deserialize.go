@@ -2,9 +2,13 @@ package ezbson
 
 import (
 	bytelib "bytes"
+	"encoding"
 	binlib "encoding/binary"
 	"fmt"
+	"math"
 	"reflect"
+	regexplib "regexp"
+	"strconv"
 	timelib "time"
 )
 
@@ -37,36 +41,115 @@ const (
 //
 // Below are the supported types that Unmarshal can convert:
 //
-//	// +----------------------+---------------------------+
-//	// | bson type            | golang type               |
-//	// +----------------------+---------------------------+
-//	// | double (1)           | float64                   |
-//	// | string (2)           | string                    |
-//	// | document (3)         | struct, or map[string]... |
-//	// | array (4)            | []...                     |
-//	// | binary (5)           | []byte                    |
-//	// | deprecated (6)       | <NOT IMPLEMENTED>         |
-//	// | objectid (7)         | <NOT IMPLEMENTED>         |
-//	// | boolean (8)          | bool                      |
-//	// | UTC datetime (9)     | time.Time                 |
-//	// | null (10)            | <NOT IMPLEMENTED>         |
-//	// | regex (11)           | <NOT IMPLEMENTED>         |
-//	// | deprecated (12)      | <NOT IMPLEMENTED>         |
-//	// | javascript code (13) | <NOT IMPLEMENTED>         |
-//	// | symbol (14)          | <NOT IMPLEMENTED>         |
-//	// | deprecated (15)      | <NOT IMPLEMENTED>         |
-//	// | int32 (16)           | int32                     |
-//	// | mongo timestamp (17) | <NOT IMPLEMENTED>         |
-//	// | int64 (18)           | int64 or int              |
-//	// | decimal128 (19)      | <NOT IMPLEMENTED>         |
-//	// | min_key (-1)         | <NOT IMPLEMENTED>         |
-//	// | max_key (-1)         | <NOT IMPLEMENTED>         |
-//	// +----------------------+---------------------------+
+//	// +----------------------+---------------------------------+
+//	// | bson type            | golang type                     |
+//	// +----------------------+---------------------------------+
+//	// | double (1)           | float64, float32 (*)            |
+//	// | string (2)           | string                          |
+//	// | document (3)         | struct, map[string]... or Raw   |
+//	// | array (4)            | []... or [N]...                 |
+//	// | binary (5)           | []byte, [N]byte, Binary or UUID |
+//	// | undefined (6)        | Undefined (or zero-value)       |
+//	// | objectid (7)         | ezbson.ObjectID                 |
+//	// | boolean (8)          | bool                            |
+//	// | UTC datetime (9)     | time.Time                       |
+//	// | null (10)            | zero-value (nil, 0, ...)        |
+//	// | regex (11)           | Regex or *regexp.Regexp         |
+//	// | dbpointer (12)       | DBPointer                       |
+//	// | javascript code (13) | JavaScript or string            |
+//	// | symbol (14)          | Symbol or string                |
+//	// | code w/ scope (15)   | CodeWithScope                   |
+//	// | int32 (16)           | int32 (*)                       |
+//	// | mongo timestamp (17) | Timestamp                       |
+//	// | int64 (18)           | int64 (*)                       |
+//	// | decimal128 (19)      | ezbson.Decimal128               |
+//	// | min_key (-1)         | MinKey                          |
+//	// | max_key (127)        | MaxKey                          |
+//	// +----------------------+---------------------------------+
+//
+// (*) numeric types can be deserialized into any golang integer or float kind (int8...int64, uint8...uint64, float32, float64),
+// as long as the value fits. An error is returned on overflow, when deserializing a non-integer double into an integer,
+// or when deserializing an integer that the float kind cannot represent exactly (above 2^53 for float64, or 2^24 for float32).
+// When deserializing into 'any', the golang type from the table is used.
+//
+// Like encoding/json, the fields of embedded structs (and pointers to structs) are flattened into the parent document.
+// Named fields can be flattened with the `bson:",inline"` tag, and an inline map collects the elements that don't match
+// any other field. A shallower field hides deeper fields with the same name, and fields with the same name at the same
+// depth return an error.
+//
+// Maps may have string, integer (formatted as decimal) or encoding.TextMarshaler (and TextUnmarshaler) keys,
+// like encoding/json's map keys.
+//
+// Pointers (e.g. *int64, **Inner, or the elements of map[string]*Inner) are allocated as needed, at any nesting level.
+// An existing non-nil pointee is reused (like encoding/json), and a BSON null sets the pointer to nil.
+//
+// A BSON array (or binary) must have exactly N elements to be deserialized into a golang array ([N]...),
+// unless Decoder.AllowArrayLengthMismatch is set.
+//
+// Types whose pointer implements ValueUnmarshaler or Unmarshaler unmarshal themselves, at any nesting level.
+// Otherwise, a BSON string is unmarshalled with encoding.TextUnmarshaler, and BSON binary with encoding.BinaryUnmarshaler,
+// if implemented (ezbson's own types and time.Time are excluded).
+// The functions of Decoder.Registry take precedence over all of these (see Registry).
+//
+// Types are matched by their kind, so named types can be deserialized into like their underlying type
+// (e.g. a BSON string into `type Status string`, or BSON binary into `type Hash []byte`).
+//
+// Unexported struct fields are skipped (see Decoder.DisallowUnexportedFields).
+//
+// Struct fields are matched by their `bson:"name"` tag (or by their golang name, converted by Decoder.NamingStrategy),
+// and fields tagged `bson:"-"` are skipped. See Decoder.CaseInsensitive for case-insensitive matching.
+// See Marshal for the other tag options.
+//
+// Elements that don't match any struct field are skipped without being decoded (see Decoder.DisallowUnknownFields),
+// unless the struct has an inline map or a `bson:",remainder"` field, which collects them.
+//
+// Times are returned in UTC, unless Decoder.TimeLocation is set. time.Time fields tagged with unixnano or rfc3339nano
+// (see Marshal) are deserialized from an int64 or a string respectively, as well as from a UTC datetime.
+// rfc3339nano times keep their zone offset (as a fixed zone), unless Decoder.TimeLocation is set.
 //
 // Limitations:
-//   - due to the way reflect works, all structs that are being marshalled must only contain exported (uppercase) fields.
 //   - as of right now, only 64 bit architectures are supported.
 func Unmarshal(marshalled []byte, ptr any) error {
+	return (&Decoder{}).Unmarshal(marshalled, ptr)
+}
+
+// Decoder holds the options used for unmarshalling.
+//
+// The zero value is ready to use, and behaves like the package-level Unmarshal.
+// A Decoder must not be modified while it is being used.
+type Decoder struct {
+	// AllowArrayLengthMismatch allows unmarshalling a BSON array (or binary) into a golang array of a different length:
+	// extra elements are dropped, and missing elements are left as zero-values.
+	// By default, a length mismatch returns an error.
+	AllowArrayLengthMismatch bool
+
+	// DisallowUnexportedFields returns an error when a document contains an element whose name is that of
+	// an unexported struct field (unless the struct has an inline map or a remainder field, which collects it).
+	// By default, such elements are skipped (like unexported fields are skipped by Marshal), or collected by the remainder.
+	DisallowUnexportedFields bool
+
+	// NamingStrategy converts the golang names of untagged struct fields into enames (by default, they are used as-is).
+	// It should match the Encoder.NamingStrategy that the document was marshalled with.
+	NamingStrategy NamingStrategy
+
+	// DisallowUnknownFields returns an error when a document contains an element that doesn't match any struct field
+	// (unless the struct has an inline map or a remainder field). By default, such elements are skipped without being decoded.
+	DisallowUnknownFields bool
+
+	// CaseInsensitive matches elements to struct fields case-insensitively, if there is no exact match
+	// (e.g. "userid" matches a field named "UserID").
+	CaseInsensitive bool
+
+	// Registry holds custom decode functions, which take precedence over everything else (may be nil).
+	Registry *Registry
+
+	// TimeLocation is the location that decoded times are converted into (e.g. time.Local).
+	// By default, times are returned in UTC (except for rfc3339nano fields, which keep the offset they were marshalled with).
+	TimeLocation *timelib.Location
+}
+
+// Unmarshal is like the package-level Unmarshal, but uses the options set on dec.
+func (dec *Decoder) Unmarshal(marshalled []byte, ptr any) error {
 	if err := validate64bit(); err != nil {
 		return fmt.Errorf("ezbson.Unmarshal: %w", err)
 	}
@@ -76,6 +159,26 @@ func Unmarshal(marshalled []byte, ptr any) error {
 	if reflect.TypeOf(ptr).Kind() != reflect.Ptr {
 		return fmt.Errorf("ezbson.Unmarshal: ptr must be a pointer")
 	}
+
+	if decode := dec.Registry.lookupDecoder(reflect.TypeOf(ptr).Elem()); decode != nil {
+		if err := Raw(marshalled).Validate(); err != nil {
+			return fmt.Errorf("ezbson.Unmarshal: %w", err)
+		}
+		if _, err := readIntoDecodeFunc(buffer, ptr, kEtypeDocument, decode); err != nil {
+			return fmt.Errorf("ezbson.Unmarshal: %w", err)
+		}
+		return nil
+	}
+
+	if isUnmarshalerRtype(reflect.TypeOf(ptr).Elem()) {
+		if err := Raw(marshalled).Validate(); err != nil {
+			return fmt.Errorf("ezbson.Unmarshal: %w", err)
+		}
+		if _, err := readIntoUnmarshaler(buffer, ptr, kEtypeDocument); err != nil {
+			return fmt.Errorf("ezbson.Unmarshal: %w", err)
+		}
+		return nil
+	}
 	valRtype := reflect.TypeOf(ptr).Elem()
 	valRkind := valRtype.Kind()
 
@@ -84,9 +187,9 @@ func Unmarshal(marshalled []byte, ptr any) error {
 
 	switch valRkind {
 	case reflect.Struct:
-		numread, err = readStruct(buffer, ptr)
+		numread, err = dec.readStruct(buffer, ptr)
 	case reflect.Map:
-		numread, err = readMap(buffer, ptr)
+		numread, err = dec.readMap(buffer, ptr)
 	default:
 		return fmt.Errorf("ezbson.Unmarshal: only structs or maps are supported at the top level")
 	}
@@ -104,7 +207,7 @@ func Unmarshal(marshalled []byte, ptr any) error {
 }
 
 // Returns the amount of bytes read (only valid if error is nil)
-func readStruct(buffer *bytelib.Buffer, structptr any) (numread int, err error) {
+func (dec *Decoder) readStruct(buffer *bytelib.Buffer, structptr any) (numread int, err error) {
 	var expectedSize int32
 	var actualSize int
 
@@ -115,6 +218,13 @@ func readStruct(buffer *bytelib.Buffer, structptr any) (numread int, err error)
 
 	struct_rvalue := reflect.Indirect(reflect.ValueOf(structptr))
 
+	fields, err := getStructFields(struct_rvalue.Type(), dec.NamingStrategy)
+	if err != nil {
+		return 0, err
+	}
+
+	var remainderElements []byte // The unmatched elements, when the remainder field is a Raw
+
 	for {
 		var et etype
 		if numread, err = readEtype(buffer, &et); err != nil {
@@ -126,6 +236,9 @@ func readStruct(buffer *bytelib.Buffer, structptr any) (numread int, err error)
 			if actualSize != int(expectedSize) {
 				return 0, fmt.Errorf("expected size (%v) does not match actual size (%v)", expectedSize, actualSize)
 			}
+			if remainderElements != nil {
+				fieldByIndexAlloc(struct_rvalue, fields.remainder.index).Set(reflect.ValueOf(rawFromElements(remainderElements)))
+			}
 			return actualSize, nil
 		}
 
@@ -135,20 +248,69 @@ func readStruct(buffer *bytelib.Buffer, structptr any) (numread int, err error)
 		}
 		actualSize += numread
 
-		field_rvalue := struct_rvalue.FieldByName(ename)
-		if field_rvalue == (reflect.Value{}) {
-			return 0, fmt.Errorf("field {%v} not found", ename)
+		field, ok := fields.lookup(ename, dec.CaseInsensitive)
+		if !ok && fields.remainder == nil {
+			// An element named like an unexported field is an unknown element, with a more specific error
+			if dec.DisallowUnexportedFields && fields.unexported[ename] {
+				return 0, fmt.Errorf("field {%v} is unexported (see Decoder.DisallowUnexportedFields)", ename)
+			}
+			if dec.DisallowUnknownFields {
+				return 0, fmt.Errorf("field {%v} not found (see Decoder.DisallowUnknownFields)", ename)
+			}
+
+			if numread, err = dec.skipEvalue(buffer, et); err != nil {
+				return 0, fmt.Errorf("field {%v}: %w", ename, err)
+			}
+			actualSize += numread
+			continue
+		}
+
+		if !ok {
+			// Elements that don't match any field are collected by the remainder (an inline map, or a Raw)
+			remainderRvalue := fieldByIndexAlloc(struct_rvalue, fields.remainder.index)
+
+			if remainderRvalue.Type() == reflect.TypeOf(Raw{}) {
+				raw, err := readRawEvalue(buffer, et)
+				if err != nil {
+					return 0, fmt.Errorf("field {%v}: %w", ename, err)
+				}
+				actualSize += len(raw)
+
+				remainderElements = append(remainderElements, byte(et))
+				remainderElements = append(remainderElements, ename...)
+				remainderElements = append(remainderElements, kNullTerminator)
+				remainderElements = append(remainderElements, raw...)
+				continue
+			}
+
+			if remainderRvalue.IsNil() {
+				remainderRvalue.Set(reflect.MakeMap(remainderRvalue.Type()))
+			}
+
+			if numread, err = dec.readMapElement(buffer, remainderRvalue, ename, et); err != nil {
+				return 0, err
+			}
+			actualSize += numread
+			continue
+		}
+
+		field_rvalue := fieldByIndexAlloc(struct_rvalue, field.index)
+
+		if field.timeFormat != kTimeFormatDatetime && et == field.timeFormat.etype() {
+			// Other etypes (e.g. a UTC datetime, or null) are read as usual below
+			if numread, err = dec.readTimeEvalue(buffer, field_rvalue, field.timeFormat); err != nil {
+				return 0, fmt.Errorf("field {%v}: %w", ename, err)
+			}
+			actualSize += numread
+			continue
 		}
 
 		field_rtype := field_rvalue.Type()
-		if err = validateEtypeCanBeDeserializeToRtype(et, field_rtype); err != nil {
+		if err = dec.validateEtypeCanBeDeserializeToRtype(et, field_rtype); err != nil {
 			return 0, fmt.Errorf("field {%v}: %w", ename, err)
 		}
 
-		fieldptr_rvalue := field_rvalue.Addr()
-		fieldptr_any := fieldptr_rvalue.Interface()
-
-		if numread, err = readEvalue(buffer, fieldptr_any, et); err != nil {
+		if numread, err = dec.readEvalue(buffer, field_rvalue.Addr().Interface(), et); err != nil {
 			return 0, fmt.Errorf("field {%v}: %w", ename, err)
 		}
 		actualSize += numread
@@ -161,16 +323,173 @@ func emptyInterfaceRtype() reflect.Type {
 	return reflect.TypeOf(s).Elem()
 }
 
-func validateEtypeCanBeDeserializeToRtype(et etype, rtype reflect.Type) error {
+// newEvaluePtr returns a pointer to a fresh value that an evalue of type et can be read into,
+// and that can later be stored in a container whose elements are of type elemRtype.
+// When elemRtype is 'any', the natural golang-type for et is used (see the table at Unmarshal).
+func newEvaluePtr(et etype, elemRtype reflect.Type) any {
+	if elemRtype != emptyInterfaceRtype() {
+		return reflect.New(elemRtype).Interface()
+	}
+
+	switch et {
+	case kEtypeDouble:
+		return new(float64)
+	case kEtypeString:
+		return new(string)
+	case kEtypeBinary:
+		return new(any) // Either []byte, UUID or Binary, depending on the subtype (see readEvalue)
+	case kEtypeObjectId:
+		return new(ObjectID)
+	case kEtypeBoolean:
+		return new(bool)
+	case kEtypeUtcDatetime:
+		return new(timelib.Time)
+	case kEtypeInt32:
+		return new(int32)
+	case kEtypeMongoTimestamp:
+		return new(Timestamp)
+	case kEtypeInt64:
+		return new(int64)
+	case kEtypeDecimal128:
+		return new(Decimal128)
+	case kEtypeMinKey:
+		return new(MinKey)
+	case kEtypeMaxKey:
+		return new(MaxKey)
+	case kEtypeDocument:
+		var tmp = make(map[string]any)
+		return &tmp
+	case kEtypeArray:
+		var tmp = make([]any, 0)
+		return &tmp
+	case kEtypeUndefined:
+		return new(Undefined)
+	case kEtypeDBPointer:
+		return new(DBPointer)
+	case kEtypeSymbol:
+		return new(Symbol)
+	case kEtypeRegex:
+		return new(Regex)
+	case kEtypeJavascriptCode:
+		return new(JavaScript)
+	case kEtypeCodeWithScope:
+		return new(CodeWithScope)
+	default:
+		return new(any)
+	}
+}
+
+// isAllocatablePointerRtype reports whether rtype is a pointer that the decoder allocates (and reads into its pointee).
+// *regexp.Regexp is excluded, as it is deserialized from a BSON regex as a whole,
+// and so are pointer types that have their own DecodeFunc in dec.Registry (e.g. *time.Location).
+func (dec *Decoder) isAllocatablePointerRtype(rtype reflect.Type) bool {
+	if rtype.Kind() != reflect.Pointer || rtype == reflect.TypeOf(&regexplib.Regexp{}) {
+		return false
+	}
+
+	return !dec.Registry.hasTypeDecoder(rtype)
+}
+
+func isNumericRkind(rkind reflect.Kind) bool {
+	switch rkind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// setInt stores an integer evalue (int32 or int64) into a numeric rvalue.
+// An error is returned if val does not fit.
+func setInt(rvalue reflect.Value, val int64) error {
+	switch rvalue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rvalue.OverflowInt(val) {
+			return fmt.Errorf("value %v overflows %v", val, rvalue.Type())
+		}
+		rvalue.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val < 0 || rvalue.OverflowUint(uint64(val)) {
+			return fmt.Errorf("value %v overflows %v", val, rvalue.Type())
+		}
+		rvalue.SetUint(uint64(val))
+	case reflect.Float32, reflect.Float64:
+		f := float64(val)
+		if rvalue.Kind() == reflect.Float32 {
+			f = float64(float32(val))
+		}
+		if f >= math.MaxInt64 || int64(f) != val { // float64(math.MaxInt64) is 2^63, which doesn't fit in an int64
+			return fmt.Errorf("value %v cannot be represented exactly as %v", val, rvalue.Type())
+		}
+		rvalue.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot convert an integer to %v", rvalue.Type())
+	}
+
+	return nil
+}
+
+// setFloat stores a double evalue into a numeric rvalue.
+// An error is returned if val does not fit, or if the rvalue is an integer and val is not a whole number.
+func setFloat(rvalue reflect.Value, val float64) error {
+	switch rvalue.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if rvalue.OverflowFloat(val) {
+			return fmt.Errorf("value %v overflows %v", val, rvalue.Type())
+		}
+		rvalue.SetFloat(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val != math.Trunc(val) {
+			return fmt.Errorf("cannot convert non-integer value %v to %v", val, rvalue.Type())
+		}
+		if val < math.MinInt64 || val >= math.MaxInt64 {
+			return fmt.Errorf("value %v overflows %v", val, rvalue.Type())
+		}
+		return setInt(rvalue, int64(val))
+	default:
+		return fmt.Errorf("cannot convert a double to %v", rvalue.Type())
+	}
+
+	return nil
+}
+
+func (dec *Decoder) validateEtypeCanBeDeserializeToRtype(et etype, rtype reflect.Type) error {
 	var rkind = rtype.Kind()
 
 	if rtype == emptyInterfaceRtype() { // We can always deserialize into 'any'
 		return nil
 	}
 
+	if et == kEtypeNull || et == kEtypeUndefined { // null (and undefined) are deserialized into the zero-value of any type
+		return nil
+	}
+
+	if dec.isAllocatablePointerRtype(rtype) { // Pointers are validated by their pointee (e.g. *int64 as int64)
+		return dec.validateEtypeCanBeDeserializeToRtype(et, rtype.Elem())
+	}
+
+	if dec.Registry.lookupDecoder(rtype) != nil { // A registered DecodeFunc accepts any etype
+		return nil
+	}
+	if reflect.PointerTo(rtype).Implements(valueUnmarshalerRtype) { // A ValueUnmarshaler accepts any etype
+		return nil
+	}
+	if isEncodingUnmarshalerRtype(rtype, et) {
+		return nil
+	}
+	if reflect.PointerTo(rtype).Implements(unmarshalerRtype) {
+		if et != kEtypeDocument {
+			return fmt.Errorf("cannot convert etype %v to %v (Unmarshaler only supports documents)", et, rtype)
+		}
+		return nil
+	}
+
 	switch et {
 	case kEtypeDouble:
-		if rkind != reflect.Float64 {
+		if !isNumericRkind(rkind) {
 			return fmt.Errorf("cannot convert double (etype %v) to %v", et, rtype)
 		}
 	case kEtypeString:
@@ -178,9 +497,13 @@ func validateEtypeCanBeDeserializeToRtype(et etype, rtype reflect.Type) error {
 			return fmt.Errorf("cannot convert string (etype %v) to %v", et, rtype)
 		}
 	case kEtypeBinary:
-		if rtype != reflect.TypeOf(make([]byte, 0)) {
+		if !isByteSliceRtype(rtype) && !isByteArrayRtype(rtype) && rtype != reflect.TypeOf(Binary{}) && rtype != reflect.TypeOf(UUID{}) {
 			return fmt.Errorf("cannot convert binary (etype %v) to %v", et, rtype)
 		}
+	case kEtypeObjectId:
+		if rtype != reflect.TypeOf(ObjectID{}) {
+			return fmt.Errorf("cannot convert ObjectId (etype %v) to %v", et, rtype)
+		}
 	case kEtypeBoolean:
 		if rkind != reflect.Bool {
 			return fmt.Errorf("cannot convert boolean (etype %v) to %v", et, rtype)
@@ -189,20 +512,57 @@ func validateEtypeCanBeDeserializeToRtype(et etype, rtype reflect.Type) error {
 		if rtype != reflect.TypeOf(timelib.Time{}) {
 			return fmt.Errorf("cannot convert UtcDatetime (etype %v) to %v", et, rtype)
 		}
+	case kEtypeRegex:
+		if rtype != reflect.TypeOf(Regex{}) && rtype != reflect.TypeOf(&regexplib.Regexp{}) {
+			return fmt.Errorf("cannot convert Regex (etype %v) to %v", et, rtype)
+		}
+	case kEtypeJavascriptCode:
+		if rkind != reflect.String {
+			return fmt.Errorf("cannot convert JavaScript (etype %v) to %v", et, rtype)
+		}
+	case kEtypeCodeWithScope:
+		if rtype != reflect.TypeOf(CodeWithScope{}) {
+			return fmt.Errorf("cannot convert CodeWithScope (etype %v) to %v", et, rtype)
+		}
+	case kEtypeDBPointer:
+		if rtype != reflect.TypeOf(DBPointer{}) {
+			return fmt.Errorf("cannot convert DBPointer (etype %v) to %v", et, rtype)
+		}
+	case kEtypeSymbol:
+		if rkind != reflect.String {
+			return fmt.Errorf("cannot convert Symbol (etype %v) to %v", et, rtype)
+		}
 	case kEtypeInt32:
-		if rkind != reflect.Int32 {
+		if !isNumericRkind(rkind) {
 			return fmt.Errorf("cannot convert int32 (etype %v) to %v", et, rtype)
 		}
+	case kEtypeMongoTimestamp:
+		if rtype != reflect.TypeOf(Timestamp{}) {
+			return fmt.Errorf("cannot convert Timestamp (etype %v) to %v", et, rtype)
+		}
 	case kEtypeInt64:
-		if rkind != reflect.Int64 && rkind != reflect.Int {
+		if !isNumericRkind(rkind) {
 			return fmt.Errorf("cannot convert int64 (etype %v) to %v", et, rtype)
 		}
+	case kEtypeDecimal128:
+		if rtype != reflect.TypeOf(Decimal128{}) {
+			return fmt.Errorf("cannot convert Decimal128 (etype %v) to %v", et, rtype)
+		}
+	case kEtypeMinKey:
+		if rtype != reflect.TypeOf(MinKey{}) {
+			return fmt.Errorf("cannot convert MinKey (etype %v) to %v", et, rtype)
+		}
+	case kEtypeMaxKey:
+		if rtype != reflect.TypeOf(MaxKey{}) {
+			return fmt.Errorf("cannot convert MaxKey (etype %v) to %v", et, rtype)
+		}
 	case kEtypeArray:
-		if rkind != reflect.Slice {
+		if rkind != reflect.Slice && rkind != reflect.Array {
 			return fmt.Errorf("cannot convert Array (etype %v) to %v", et, rtype)
 		}
 	case kEtypeDocument:
-		if rkind != reflect.Struct && rkind != reflect.Map {
+		isDocumentStruct := rkind == reflect.Struct && !nativeRtypes[rtype] // e.g. not a time.Time or a Decimal128
+		if !isDocumentStruct && rkind != reflect.Map && rtype != reflect.TypeOf(Raw{}) {
 			return fmt.Errorf("cannot convert Document (etype %v) to %v", et, rtype)
 		}
 	}
@@ -210,18 +570,14 @@ func validateEtypeCanBeDeserializeToRtype(et etype, rtype reflect.Type) error {
 	return nil
 }
 
-func readMap(buffer *bytelib.Buffer, mapptr any) (numread int, err error) {
+func (dec *Decoder) readMap(buffer *bytelib.Buffer, mapptr any) (numread int, err error) {
 	var expectedSize int32
 	var actualSize int
 
 	mapRtype := reflect.TypeOf(mapptr).Elem()
-	mapKeyRtype := mapRtype.Key()
-	mapKeyRkind := mapKeyRtype.Kind()
-	mapElemRtype := mapRtype.Elem()
-	mapElemRkind := mapElemRtype.Kind()
 
-	if mapKeyRkind != reflect.String {
-		return 0, fmt.Errorf("only map[string]... is supported")
+	if err = validateMapKeyRtype(mapRtype.Key()); err != nil {
+		return 0, err
 	}
 
 	if numread, err = readInt32(buffer, &expectedSize); err != nil {
@@ -252,124 +608,279 @@ func readMap(buffer *bytelib.Buffer, mapptr any) (numread int, err error) {
 		}
 		actualSize += numread
 
-		if err = validateEtypeCanBeDeserializeToRtype(et, mapElemRtype); err != nil {
-			return 0, fmt.Errorf("field {%v}: %w", ename, err)
+		if numread, err = dec.readMapElement(buffer, mapRvalue, ename, et); err != nil {
+			return 0, err
 		}
+		actualSize += numread
+	}
+}
 
-		// map values aren't addressable in golang, so we need to read into a temporary variable.
-		// tmpptr is a pointer to a concrete-type (stored in an 'any' interface)
-
-		var tmpptr any
-		switch et {
-		case kEtypeDouble:
-			var tmp float64
-			tmpptr = &tmp
-		case kEtypeString:
-			var tmp string
-			tmpptr = &tmp
-		case kEtypeBinary:
-			var tmp []byte
-			tmpptr = &tmp
-		case kEtypeBoolean:
-			var tmp bool
-			tmpptr = &tmp
-		case kEtypeUtcDatetime:
-			var tmp timelib.Time
-			tmpptr = &tmp
-		case kEtypeInt32:
-			var tmp int32
-			tmpptr = &tmp
-		case kEtypeInt64:
-			if mapElemRkind == reflect.Int {
-				var tmp int
-				tmpptr = &tmp
-			} else {
-				var tmp int64
-				tmpptr = &tmp
-			}
-		case kEtypeDocument:
-			switch mapElemRkind {
-			case reflect.Struct:
-				var tmp = reflect.New(mapElemRtype)
-				tmpptr = tmp.Interface()
-			case reflect.Map:
-				tmpmap := reflect.MakeMap(mapElemRtype) // https://stackoverflow.com/a/25386460
-				tmpptr_rvalue := reflect.New(mapElemRtype)
-				tmpptr_rvalue.Elem().Set(tmpmap)
-				tmpptr = tmpptr_rvalue.Interface()
+// skipEvalue reads an evalue of type et, and discards it (without decoding it).
+func (dec *Decoder) skipEvalue(buffer *bytelib.Buffer, et etype) (numread int, err error) {
+	raw, err := readRawEvalue(buffer, et)
+	if err != nil {
+		return 0, err
+	}
 
-			default:
-				_, ok := mapptr.(*map[string]any)
-				if !ok {
-					return 0, fmt.Errorf("field %v: cannot deserialize a document into {%v}", ename, mapElemRtype)
-				}
+	return len(raw), nil
+}
 
-				// Deserialize the element into a map[string]any
-				var tmp = make(map[string]any)
-				tmpptr = &tmp
-			}
-		case kEtypeArray:
-			switch mapElemRkind {
-			case reflect.Slice:
-				tmpslice := reflect.MakeSlice(mapElemRtype, 0, 0) // https://stackoverflow.com/a/25386460
-				tmpptr_rvalue := reflect.New(mapElemRtype)
-				tmpptr_rvalue.Elem().Set(tmpslice)
-				tmpptr = tmpptr_rvalue.Interface()
-			default:
-				_, ok := mapptr.(*map[string]any)
-				if !ok {
-					return 0, fmt.Errorf("field %v: cannot deserialize a slice into {%v}", ename, mapElemRtype)
-				}
+// readRawEvalue reads an evalue of type et without decoding it. Its size is determined by et, or by its size prefixes.
+func readRawEvalue(buffer *bytelib.Buffer, et etype) (raw []byte, err error) {
+	size, err := evalueSize(buffer.Bytes(), et)
+	if err != nil {
+		return nil, err
+	}
 
-				var tmp = make([]any, 0)
-				tmpptr = &tmp
-			}
-		default:
-			return 0, fmt.Errorf("field %v: unsupported etype %v", ename, et)
+	return buffer.Next(size), nil
+}
+
+// evalueSize returns the size of the evalue of type et at the start of data.
+func evalueSize(data []byte, et etype) (int, error) {
+	int32At := func(offset int) (int, error) {
+		if len(data) < offset+kInt32Size {
+			return 0, fmt.Errorf("unexpected end of buffer")
+		}
+		val := int32(binlib.LittleEndian.Uint32(data[offset:]))
+		if val < 0 {
+			return 0, fmt.Errorf("negative size (%v)", val)
 		}
+		return int(val), nil
+	}
 
-		if numread, err = readEvalue(buffer, tmpptr, et); err != nil {
-			return 0, fmt.Errorf("field {%v}: %w", ename, err)
+	cstringSize := func(offset int) (int, error) {
+		if offset > len(data) {
+			return 0, fmt.Errorf("unexpected end of buffer")
 		}
-		actualSize += numread
+		pos := bytelib.IndexByte(data[offset:], kNullTerminator)
+		if pos < 0 {
+			return 0, fmt.Errorf("unterminated cstring")
+		}
+		return pos + 1, nil
+	}
 
-		tmpptr_rvalue := reflect.ValueOf(tmpptr)
+	var size int
+	var err error
+
+	switch et {
+	case kEtypeUndefined, kEtypeNull, kEtypeMinKey, kEtypeMaxKey:
+		size = 0
+	case kEtypeBoolean:
+		size = kInt8Size
+	case kEtypeInt32:
+		size = kInt32Size
+	case kEtypeDouble, kEtypeUtcDatetime, kEtypeMongoTimestamp, kEtypeInt64:
+		size = kInt64Size
+	case kEtypeObjectId:
+		size = len(ObjectID{})
+	case kEtypeDecimal128:
+		size = 2 * kInt64Size
+	case kEtypeString, kEtypeJavascriptCode, kEtypeSymbol:
+		size, err = int32At(0)
+		size += kInt32Size
+	case kEtypeBinary:
+		size, err = int32At(0)
+		size += kInt32Size + kSubtypeSize
+	case kEtypeDocument, kEtypeArray, kEtypeCodeWithScope:
+		size, err = int32At(0) // The size includes itself
+	case kEtypeDBPointer:
+		size, err = int32At(0)
+		size += kInt32Size + len(ObjectID{})
+	case kEtypeRegex:
+		var patternSize, optionsSize int
+		if patternSize, err = cstringSize(0); err == nil {
+			optionsSize, err = cstringSize(patternSize)
+		}
+		size = patternSize + optionsSize
+	default:
+		return 0, fmt.Errorf("unknown etype %v", et)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	if size > len(data) {
+		return 0, fmt.Errorf("evalue size (%v) exceeds the remaining buffer (%v)", size, len(data))
+	}
+
+	return size, nil
+}
 
-		mapRvalue.SetMapIndex(reflect.ValueOf(ename), tmpptr_rvalue.Elem())
+// readMapElement reads an evalue, and stores it in the (non-nil) map mapRvalue under the key parsed from ename.
+func (dec *Decoder) readMapElement(buffer *bytelib.Buffer, mapRvalue reflect.Value, ename string, et etype) (numread int, err error) {
+	mapKeyRtype := mapRvalue.Type().Key()
+	mapElemRtype := mapRvalue.Type().Elem()
+
+	key, err := parseMapKey(ename, mapKeyRtype)
+	if err != nil {
+		return 0, fmt.Errorf("field {%v}: %w", ename, err)
+	}
+
+	if err = dec.validateEtypeCanBeDeserializeToRtype(et, mapElemRtype); err != nil {
+		return 0, fmt.Errorf("field {%v}: %w", ename, err)
+	}
+
+	// map values aren't addressable in golang, so we need to read into a temporary variable.
+	// tmpptr is a pointer to a concrete-type (stored in an 'any' interface)
+	tmpptr := newEvaluePtr(et, mapElemRtype)
+
+	if numread, err = dec.readEvalue(buffer, tmpptr, et); err != nil {
+		return 0, fmt.Errorf("field {%v}: %w", ename, err)
 	}
+
+	tmpptr_rvalue := reflect.ValueOf(tmpptr)
+
+	mapRvalue.SetMapIndex(key, tmpptr_rvalue.Elem())
+	return numread, nil
+}
+
+// parseMapKey converts an ename into a map key of type keyRtype (the inverse of formatMapKey).
+func parseMapKey(ename string, keyRtype reflect.Type) (reflect.Value, error) {
+	key := reflect.New(keyRtype).Elem()
+
+	if keyRtype.Kind() == reflect.String {
+		key.SetString(ename) // SetString supports named string keys
+		return key, nil
+	}
+
+	if unmarshaler, ok := key.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(ename)); err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot unmarshal key into %v: %w", keyRtype, err)
+		}
+		return key, nil
+	}
+
+	switch keyRtype.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err := strconv.ParseInt(ename, 10, keyRtype.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot parse key as %v: %w", keyRtype, err)
+		}
+		key.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err := strconv.ParseUint(ename, 10, keyRtype.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot parse key as %v: %w", keyRtype, err)
+		}
+		key.SetUint(val)
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported map key type %v", keyRtype)
+	}
+
+	return key, nil
 }
 
 // a struct in bson is a sequence of [etype ename evalue].
 // This function receives a generic pointer and an etype, and reads the evalue into it.
-func readEvalue(buffer *bytelib.Buffer, ptr_any any, et etype) (numread int, err error) {
+func (dec *Decoder) readEvalue(buffer *bytelib.Buffer, ptr_any any, et etype) (numread int, err error) {
+	rvalue := reflect.ValueOf(ptr_any).Elem()
+
+	// Pointers (e.g. *int64 or **Inner) are allocated as needed, and the evalue is read into the pointee.
+	// An existing pointee is reused. On null, the pointer itself is set to nil (see kEtypeNull below).
+	if dec.isAllocatablePointerRtype(rvalue.Type()) && et != kEtypeNull && et != kEtypeUndefined {
+		if rvalue.IsNil() {
+			rvalue.Set(reflect.New(rvalue.Type().Elem()))
+		}
+		return dec.readEvalue(buffer, rvalue.Interface(), et)
+	}
+
+	if decode := dec.Registry.lookupDecoder(rvalue.Type()); decode != nil && et != kEtypeNull && et != kEtypeUndefined {
+		return readIntoDecodeFunc(buffer, ptr_any, et, decode)
+	}
+
+	if isUnmarshalerRtype(rvalue.Type()) && et != kEtypeNull && et != kEtypeUndefined {
+		return readIntoUnmarshaler(buffer, ptr_any, et)
+	}
+
+	if isEncodingUnmarshalerRtype(rvalue.Type(), et) {
+		return readIntoEncodingUnmarshaler(buffer, ptr_any, et)
+	}
+
+	// 'any' is read into a temporary variable of the natural type for et (see newEvaluePtr).
+	if rvalue.Type() == emptyInterfaceRtype() {
+		tmpptr := newEvaluePtr(et, rvalue.Type())
+		if _, isAnyPtr := tmpptr.(*any); !isAnyPtr {
+			if numread, err = dec.readEvalue(buffer, tmpptr, et); err != nil {
+				return 0, err
+			}
+			rvalue.Set(reflect.ValueOf(tmpptr).Elem())
+			return numread, nil
+		}
+	}
+
 	switch et {
 	case kEtypeDouble:
-		ptr := ptr_any.(*float64)
+		var val float64
+		if numread, err = readFloat64(buffer, &val); err != nil {
+			return 0, err
+		}
 
-		if numread, err = readFloat64(buffer, ptr); err != nil {
+		if err = setFloat(reflect.ValueOf(ptr_any).Elem(), val); err != nil {
 			return 0, err
 		}
 
 	case kEtypeString:
-		ptr := ptr_any.(*string)
-
-		if numread, err = readEstring(buffer, ptr); err != nil {
+		var val string
+		if numread, err = readEstring(buffer, &val); err != nil {
 			return 0, err
 		}
 
+		reflect.ValueOf(ptr_any).Elem().SetString(val)
+
 	case kEtypeBinary:
-		ptr := ptr_any.(*[]byte)
+		var val Binary
+		if numread, err = readEbinary(buffer, &val); err != nil {
+			return 0, err
+		}
+
+		switch ptr := ptr_any.(type) {
+		case *Binary:
+			*ptr = val
+		case *UUID:
+			if val.Subtype != BinarySubtypeUUID {
+				return 0, fmt.Errorf("cannot convert binary subtype %v to UUID", val.Subtype)
+			}
+			*ptr = UUID(val.Data)
+		case *any:
+			// The generic subtype is deserialized into a []byte (as it is serialized from one),
+			// the UUID subtype into a UUID, and others into Binary.
+			switch val.Subtype {
+			case BinarySubtypeGeneric:
+				*ptr = val.Data
+			case BinarySubtypeUUID:
+				*ptr = UUID(val.Data)
+			default:
+				*ptr = val
+			}
+		default:
+			rvalue := reflect.ValueOf(ptr_any).Elem()
+			switch {
+			case isByteSliceRtype(rvalue.Type()):
+				rvalue.SetBytes(val.Data)
+			case isByteArrayRtype(rvalue.Type()):
+				if err = dec.setByteArray(rvalue, val.Data); err != nil {
+					return 0, err
+				}
+			default:
+				return 0, fmt.Errorf("cannot convert etype binary to %v", rvalue.Type())
+			}
+		}
 
-		if numread, err = readEbinary(buffer, ptr); err != nil {
+	case kEtypeObjectId:
+		ptr := ptr_any.(*ObjectID)
+
+		if numread, err = readObjectID(buffer, ptr); err != nil {
 			return 0, err
 		}
 
 	case kEtypeBoolean:
-		ptr := ptr_any.(*bool)
-		if numread, err = readBoolean(buffer, ptr); err != nil {
+		var val bool
+		if numread, err = readBoolean(buffer, &val); err != nil {
 			return 0, err
 		}
 
+		reflect.ValueOf(ptr_any).Elem().SetBool(val)
+
 	case kEtypeUtcDatetime:
 		ptr := ptr_any.(*timelib.Time)
 
@@ -379,46 +890,130 @@ func readEvalue(buffer *bytelib.Buffer, ptr_any any, et etype) (numread int, err
 		}
 
 		*ptr = timelib.Unix(
-			millisecFromEpoch/1e3, (millisecFromEpoch%1e3)*1e6).UTC()
+			millisecFromEpoch/1e3, (millisecFromEpoch%1e3)*1e6).In(dec.timeLocation())
 
 	case kEtypeInt32:
-		ptr := ptr_any.(*int32)
+		var val int32
+		if numread, err = readInt32(buffer, &val); err != nil {
+			return 0, err
+		}
+
+		if err = setInt(reflect.ValueOf(ptr_any).Elem(), int64(val)); err != nil {
+			return 0, err
+		}
+
+	case kEtypeMongoTimestamp:
+		ptr := ptr_any.(*Timestamp)
 
-		if numread, err = readInt32(buffer, ptr); err != nil {
+		var val int64
+		if numread, err = readInt64(buffer, &val); err != nil {
 			return 0, err
 		}
 
+		*ptr = timestampFromUint64(uint64(val))
+
 	case kEtypeInt64:
+		var val int64
+		if numread, err = readInt64(buffer, &val); err != nil {
+			return 0, err
+		}
+
+		if err = setInt(reflect.ValueOf(ptr_any).Elem(), val); err != nil {
+			return 0, err
+		}
+
+	case kEtypeDecimal128:
+		ptr := ptr_any.(*Decimal128)
+
+		if numread, err = readDecimal128(buffer, ptr); err != nil {
+			return 0, err
+		}
+
+	case kEtypeRegex:
+		var val Regex
+		if numread, err = readRegex(buffer, &val); err != nil {
+			return 0, err
+		}
+
 		switch ptr := ptr_any.(type) {
-		case *int64:
-			if numread, err = readInt64(buffer, ptr); err != nil {
-				return 0, err
-			}
-		case *int:
-			if numread, err = readInt(buffer, ptr); err != nil {
+		case *Regex:
+			*ptr = val
+		case **regexplib.Regexp:
+			if *ptr, err = val.Regexp(); err != nil {
 				return 0, err
 			}
 		default:
-			return 0, fmt.Errorf("cannot convert etype int64 to %t", ptr_any)
+			return 0, fmt.Errorf("cannot convert etype regex to %T", ptr_any)
+		}
 
+	case kEtypeJavascriptCode:
+		var code string
+		if numread, err = readEstring(buffer, &code); err != nil {
+			return 0, err
 		}
 
+		reflect.ValueOf(ptr_any).Elem().SetString(code) // JavaScript, string, or any other string kind
+
+	case kEtypeCodeWithScope:
+		ptr := ptr_any.(*CodeWithScope)
+
+		if numread, err = dec.readCodeWithScope(buffer, ptr); err != nil {
+			return 0, err
+		}
+
+	case kEtypeMinKey:
+		// min_key has no evalue.
+		*ptr_any.(*MinKey) = MinKey{}
+
+	case kEtypeMaxKey:
+		// max_key has no evalue.
+		*ptr_any.(*MaxKey) = MaxKey{}
+
+	case kEtypeUndefined:
+		// undefined has no evalue. Like null, it sets anything other than Undefined to its zero-value.
+		rvalue := reflect.ValueOf(ptr_any).Elem()
+		rvalue.Set(reflect.Zero(rvalue.Type()))
+
+	case kEtypeDBPointer:
+		ptr := ptr_any.(*DBPointer)
+
+		if numread, err = readDBPointer(buffer, ptr); err != nil {
+			return 0, err
+		}
+
+	case kEtypeSymbol:
+		var symbol string
+		if numread, err = readEstring(buffer, &symbol); err != nil {
+			return 0, err
+		}
+
+		reflect.ValueOf(ptr_any).Elem().SetString(symbol) // Symbol, string, or any other string kind
+
+	case kEtypeNull:
+		// null has no evalue. The pointee is set to its zero-value (nil for pointers, interfaces, maps and slices).
+		rvalue := reflect.ValueOf(ptr_any).Elem()
+		rvalue.Set(reflect.Zero(rvalue.Type()))
+
 	case kEtypeDocument:
 		valRtype := reflect.TypeOf(ptr_any).Elem()
 		valRkind := valRtype.Kind()
 
+		if ptr, ok := ptr_any.(*Raw); ok {
+			return readRaw(buffer, ptr)
+		}
+
 		switch valRkind {
 		case reflect.Struct:
-			numread, err = readStruct(buffer, ptr_any)
+			numread, err = dec.readStruct(buffer, ptr_any)
 		case reflect.Map:
-			numread, err = readMap(buffer, ptr_any)
+			numread, err = dec.readMap(buffer, ptr_any)
 		default:
 			return 0, fmt.Errorf("unsupported type %v", valRtype)
 		}
 		return numread, err
 
 	case kEtypeArray:
-		numread, err = readArray(buffer, ptr_any)
+		numread, err = dec.readArray(buffer, ptr_any)
 	default:
 		return 0, fmt.Errorf("unsupported etype %v", et)
 	}
@@ -426,14 +1021,15 @@ func readEvalue(buffer *bytelib.Buffer, ptr_any any, et etype) (numread int, err
 	return numread, err
 }
 
-// Mostly a copy of readMap
-func readArray(buffer *bytelib.Buffer, arrptr any) (numread int, err error) {
+// Mostly a copy of readMap. arrptr may point to either a slice or a (fixed-size) golang array.
+func (dec *Decoder) readArray(buffer *bytelib.Buffer, arrptr any) (numread int, err error) {
 	var expectedSize int32
 	var actualSize int
+	var length int
 
 	arrRtype := reflect.TypeOf(arrptr).Elem()
 	arrElemRtype := arrRtype.Elem()
-	arrElemRkind := arrElemRtype.Kind()
+	isGoArray := arrRtype.Kind() == reflect.Array
 
 	if numread, err = readInt32(buffer, &expectedSize); err != nil {
 		return 0, err
@@ -441,7 +1037,11 @@ func readArray(buffer *bytelib.Buffer, arrptr any) (numread int, err error) {
 	actualSize += numread
 
 	arrRvalue := reflect.ValueOf(arrptr).Elem()
-	arrRvalue.Set(reflect.MakeSlice(arrRtype, 0, 0)) // This changes a nil-slice to an empty slice (important for 'SetMapIndex' later).
+	if isGoArray {
+		arrRvalue.Set(reflect.Zero(arrRtype)) // Elements missing from the BSON array are left as zero-values
+	} else {
+		arrRvalue.Set(reflect.MakeSlice(arrRtype, 0, 0)) // This changes a nil-slice to an empty slice (important for 'SetMapIndex' later).
+	}
 
 	for {
 		var et etype
@@ -454,6 +1054,9 @@ func readArray(buffer *bytelib.Buffer, arrptr any) (numread int, err error) {
 			if actualSize != int(expectedSize) {
 				return 0, fmt.Errorf("expected size (%v) does not match actual size (%v)", expectedSize, actualSize)
 			}
+			if isGoArray && length < arrRvalue.Len() && !dec.AllowArrayLengthMismatch {
+				return 0, fmt.Errorf("array length (%v) does not match %v (see Decoder.AllowArrayLengthMismatch)", length, arrRtype)
+			}
 			return actualSize, nil
 		}
 
@@ -463,90 +1066,48 @@ func readArray(buffer *bytelib.Buffer, arrptr any) (numread int, err error) {
 		}
 		actualSize += numread
 
-		if err = validateEtypeCanBeDeserializeToRtype(et, arrElemRtype); err != nil {
-			return 0, fmt.Errorf("field {%v}: %w", ename, err)
+		if isGoArray && length >= arrRvalue.Len() && !dec.AllowArrayLengthMismatch {
+			return 0, fmt.Errorf("array is longer than %v (see Decoder.AllowArrayLengthMismatch)", arrRtype)
 		}
 
-		var tmpptr any
-		switch et {
-		case kEtypeDouble:
-			var tmp float64
-			tmpptr = &tmp
-		case kEtypeString:
-			var tmp string
-			tmpptr = &tmp
-		case kEtypeBinary:
-			var tmp []byte
-			tmpptr = &tmp
-		case kEtypeBoolean:
-			var tmp bool
-			tmpptr = &tmp
-		case kEtypeUtcDatetime:
-			var tmp timelib.Time
-			tmpptr = &tmp
-		case kEtypeInt32:
-			var tmp int32
-			tmpptr = &tmp
-		case kEtypeInt64:
-			if arrElemRkind == reflect.Int {
-				var tmp int
-				tmpptr = &tmp
-			} else {
-				var tmp int64
-				tmpptr = &tmp
-			}
-		case kEtypeDocument:
-			switch arrElemRkind {
-			case reflect.Struct:
-				var tmp = reflect.New(arrElemRtype)
-				tmpptr = tmp.Interface()
-			case reflect.Map:
-				tmpmap := reflect.MakeMap(arrElemRtype) // https://stackoverflow.com/a/25386460
-				tmpptr_rvalue := reflect.New(arrElemRtype)
-				tmpptr_rvalue.Elem().Set(tmpmap)
-				tmpptr = tmpptr_rvalue.Interface()
-
-			default:
-				_, ok := arrptr.(*[]any)
-				if !ok {
-					return 0, fmt.Errorf("field %v: cannot deserialize a document into {%v}", ename, arrElemRtype)
-				}
-
-				// Deserialize the element into a map[string]any
-				var tmp = make(map[string]any)
-				tmpptr = &tmp
-			}
-		case kEtypeArray:
-			switch arrElemRkind {
-			case reflect.Slice:
-				tmpslice := reflect.MakeSlice(arrElemRtype, 0, 0) // https://stackoverflow.com/a/25386460
-				tmpptr_rvalue := reflect.New(arrElemRtype)
-				tmpptr_rvalue.Elem().Set(tmpslice)
-				tmpptr = tmpptr_rvalue.Interface()
-			default:
-				_, ok := arrptr.(*[]any)
-				if !ok {
-					return 0, fmt.Errorf("field {%v}: cannot deserialize a slice into {%v}", ename, arrElemRtype)
-				}
-
-				// Deserialize the element into a []any
-				var tmp = make([]any, 0)
-				tmpptr = &tmp
-			}
-		default:
-			return 0, fmt.Errorf("unsupported etype %v", et)
+		if err = dec.validateEtypeCanBeDeserializeToRtype(et, arrElemRtype); err != nil {
+			return 0, fmt.Errorf("field {%v}: %w", ename, err)
 		}
 
-		if numread, err = readEvalue(buffer, tmpptr, et); err != nil {
+		tmpptr := newEvaluePtr(et, arrElemRtype)
+
+		if numread, err = dec.readEvalue(buffer, tmpptr, et); err != nil {
 			return 0, fmt.Errorf("field {%v}: %w", ename, err)
 		}
 		actualSize += numread
 
 		tmpptr_rvalue := reflect.ValueOf(tmpptr)
-		arrRvalue.Set(reflect.Append(arrRvalue, tmpptr_rvalue.Elem()))
+		switch {
+		case !isGoArray:
+			arrRvalue.Set(reflect.Append(arrRvalue, tmpptr_rvalue.Elem()))
+		case length < arrRvalue.Len():
+			arrRvalue.Index(length).Set(tmpptr_rvalue.Elem())
+		default:
+			// Extra elements are read (to advance the buffer), but dropped
+		}
+		length++
 	}
 }
 
+// setByteArray stores the data of a BSON binary into a golang byte array (e.g. [32]byte).
+func (dec *Decoder) setByteArray(rvalue reflect.Value, data []byte) error {
+	if len(data) != rvalue.Len() && !dec.AllowArrayLengthMismatch {
+		return fmt.Errorf("binary length (%v) does not match %v (see Decoder.AllowArrayLengthMismatch)", len(data), rvalue.Type())
+	}
+
+	rvalue.Set(reflect.Zero(rvalue.Type())) // Bytes missing from data are left as zero
+	for i := 0; i < len(data) && i < rvalue.Len(); i++ {
+		rvalue.Index(i).SetUint(uint64(data[i]))
+	}
+
+	return nil
+}
+
 func readInt32(buffer *bytelib.Buffer, val *int32) (numread int, err error) {
 	return kInt32Size, binlib.Read(buffer, binlib.LittleEndian, val)
 }
@@ -562,6 +1123,11 @@ func readEtype(buffer *bytelib.Buffer, val *etype) (numread int, err error) {
 }
 
 func readEname(buffer *bytelib.Buffer, val *string) (numread int, err error) {
+	return readCstring(buffer, val)
+}
+
+// reads a null-terminated string into val.
+func readCstring(buffer *bytelib.Buffer, val *string) (numread int, err error) {
 	ename := make([]byte, 0)
 
 	for {
@@ -579,22 +1145,25 @@ func readEname(buffer *bytelib.Buffer, val *string) (numread int, err error) {
 	}
 }
 
-// reads an int64 into val.
-func readInt(buffer *bytelib.Buffer, val *int) (numread int, err error) {
-	var tmp int64
-	numread, err = readInt64(buffer, &tmp)
-	if err != nil {
-		return numread, err
-	}
-
-	*val = int(tmp)
-	return numread, nil
-}
-
 func readInt64(buffer *bytelib.Buffer, val *int64) (numread int, err error) {
 	return kInt64Size, binlib.Read(buffer, binlib.LittleEndian, val)
 }
 
+// The low 64 bits come first (the whole 128 bits are little-endian).
+func readDecimal128(buffer *bytelib.Buffer, val *Decimal128) (numread int, err error) {
+	var h, l int64
+
+	if _, err = readInt64(buffer, &l); err != nil {
+		return 0, err
+	}
+	if _, err = readInt64(buffer, &h); err != nil {
+		return 0, err
+	}
+
+	*val = NewDecimal128(uint64(h), uint64(l))
+	return 2 * kInt64Size, nil
+}
+
 func readFloat64(buffer *bytelib.Buffer, val *float64) (numread int, err error) {
 	return kFloat64Size, binlib.Read(buffer, binlib.LittleEndian, val)
 }
@@ -625,30 +1194,213 @@ func readEstring(buffer *bytelib.Buffer, val *string) (numread int, err error) {
 	return int(sizeWithNullterm) + kInt32Size, nil
 }
 
-func readEbinary(buffer *bytelib.Buffer, val *[]byte) (numread int, err error) {
+// binary is int32 (size of the data), subtype, data.
+// For the old binary subtype (2), the data is itself int32 (size of the payload), payload.
+func readEbinary(buffer *bytelib.Buffer, val *Binary) (numread int, err error) {
 	var size int32
 
 	if _, err := readInt32(buffer, &size); err != nil {
 		return 0, err
 	}
+	if size < 0 {
+		return 0, fmt.Errorf("negative binary size (%v)", size)
+	}
 
-	_, err = buffer.ReadByte() // Consume subtype
+	subtype, err := buffer.ReadByte()
 	if err != nil {
 		return 0, err
 	}
 
 	bin := make([]byte, size)
-	if numread, err = buffer.Read(bin); err != nil {
+	if numread, err = buffer.Read(bin); err != nil && size > 0 {
 		return 0, err
 	}
 	if numread != len(bin) {
 		return 0, fmt.Errorf("expected to read %v bytes, but read %v", len(bin), numread)
 	}
 
-	*val = bin
+	if subtype == BinarySubtypeBinaryOld {
+		if len(bin) < kInt32Size {
+			return 0, fmt.Errorf("old binary subtype too short for its inner size (%v)", len(bin))
+		}
+
+		var innerSize int32
+		if _, err = readInt32(bytelib.NewBuffer(bin), &innerSize); err != nil {
+			return 0, err
+		}
+		if int(innerSize) != len(bin)-kInt32Size {
+			return 0, fmt.Errorf("old binary subtype: inner size (%v) does not match outer size (%v)", innerSize, size)
+		}
+
+		bin = bin[kInt32Size:]
+	}
+
+	if subtype == BinarySubtypeUUID && len(bin) != len(UUID{}) {
+		return 0, fmt.Errorf("UUID binary subtype must be %v bytes (got %v)", len(UUID{}), len(bin))
+	}
+
+	val.Subtype = subtype
+	val.Data = bin
 	return int(size) + kInt32Size + kSubtypeSize, nil
 }
 
+// readIntoDecodeFunc reads an evalue without decoding it, and passes it to decode (see Decoder.Registry).
+func readIntoDecodeFunc(buffer *bytelib.Buffer, ptr_any any, et etype, decode DecodeFunc) (numread int, err error) {
+	raw, err := readRawEvalue(buffer, et)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = decode(byte(et), raw, ptr_any); err != nil {
+		return 0, fmt.Errorf("decoding %T: %w", ptr_any, err)
+	}
+
+	return len(raw), nil
+}
+
+// readIntoUnmarshaler reads an evalue without decoding it, and passes it to the UnmarshalBSONValue
+// (or UnmarshalBSON) method of ptr_any.
+func readIntoUnmarshaler(buffer *bytelib.Buffer, ptr_any any, et etype) (numread int, err error) {
+	raw, err := readRawEvalue(buffer, et)
+	if err != nil {
+		return 0, err
+	}
+
+	switch unmarshaler := ptr_any.(type) {
+	case ValueUnmarshaler:
+		if err = unmarshaler.UnmarshalBSONValue(byte(et), raw); err != nil {
+			return 0, fmt.Errorf("%T.UnmarshalBSONValue: %w", ptr_any, err)
+		}
+	case Unmarshaler:
+		if et != kEtypeDocument {
+			return 0, fmt.Errorf("cannot convert etype %v to %T (Unmarshaler only supports documents)", et, ptr_any)
+		}
+		if err = unmarshaler.UnmarshalBSON(raw); err != nil {
+			return 0, fmt.Errorf("%T.UnmarshalBSON: %w", ptr_any, err)
+		}
+	default:
+		return 0, fmt.Errorf("%T is not an Unmarshaler", ptr_any)
+	}
+
+	return len(raw), nil
+}
+
+// readIntoEncodingUnmarshaler reads a string (or binary) evalue, and passes it to the UnmarshalText
+// (or UnmarshalBinary) method of ptr_any.
+func readIntoEncodingUnmarshaler(buffer *bytelib.Buffer, ptr_any any, et etype) (numread int, err error) {
+	switch et {
+	case kEtypeString:
+		var text string
+		if numread, err = readEstring(buffer, &text); err != nil {
+			return 0, err
+		}
+
+		if err = ptr_any.(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+			return 0, fmt.Errorf("%T.UnmarshalText: %w", ptr_any, err)
+		}
+	case kEtypeBinary:
+		var val Binary
+		if numread, err = readEbinary(buffer, &val); err != nil {
+			return 0, err
+		}
+
+		if err = ptr_any.(encoding.BinaryUnmarshaler).UnmarshalBinary(val.Data); err != nil {
+			return 0, fmt.Errorf("%T.UnmarshalBinary: %w", ptr_any, err)
+		}
+	default:
+		return 0, fmt.Errorf("cannot convert etype %v to %T", et, ptr_any)
+	}
+
+	return numread, nil
+}
+
+// readRaw reads an embedded document into val, without decoding it.
+func readRaw(buffer *bytelib.Buffer, val *Raw) (numread int, err error) {
+	raw, err := readRawEvalue(buffer, kEtypeDocument)
+	if err != nil {
+		return 0, err
+	}
+
+	*val = append(Raw{}, raw...) // Copy, so val doesn't alias the unmarshalled buffer
+	if err = val.Validate(); err != nil {
+		return 0, err
+	}
+
+	return len(raw), nil
+}
+
+func readObjectID(buffer *bytelib.Buffer, val *ObjectID) (numread int, err error) {
+	if numread, err = buffer.Read(val[:]); err != nil {
+		return 0, err
+	}
+	if numread != len(val) {
+		return 0, fmt.Errorf("expected to read %v bytes, but read %v", len(val), numread)
+	}
+
+	return numread, nil
+}
+
+func readRegex(buffer *bytelib.Buffer, val *Regex) (numread int, err error) {
+	var patternSize, optionsSize int
+
+	if patternSize, err = readCstring(buffer, &val.Pattern); err != nil {
+		return 0, err
+	}
+	if optionsSize, err = readCstring(buffer, &val.Options); err != nil {
+		return 0, err
+	}
+
+	if err = val.Validate(); err != nil {
+		return 0, err
+	}
+
+	return patternSize + optionsSize, nil
+}
+
+// code_w_s is int32 (total size, including itself), string (the code), document (the scope).
+func (dec *Decoder) readCodeWithScope(buffer *bytelib.Buffer, val *CodeWithScope) (numread int, err error) {
+	var expectedSize int32
+	var actualSize int
+
+	if numread, err = readInt32(buffer, &expectedSize); err != nil {
+		return 0, err
+	}
+	actualSize += numread
+
+	var code string
+	if numread, err = readEstring(buffer, &code); err != nil {
+		return 0, fmt.Errorf("code: %w", err)
+	}
+	actualSize += numread
+
+	scope := make(map[string]any)
+	if numread, err = dec.readMap(buffer, &scope); err != nil {
+		return 0, fmt.Errorf("scope: %w", err)
+	}
+	actualSize += numread
+
+	if actualSize != int(expectedSize) {
+		return 0, fmt.Errorf("code with scope: expected size (%v) does not match actual size (%v)", expectedSize, actualSize)
+	}
+
+	val.Code = JavaScript(code)
+	val.Scope = scope
+	return actualSize, nil
+}
+
+func readDBPointer(buffer *bytelib.Buffer, val *DBPointer) (numread int, err error) {
+	var refSize, idSize int
+
+	if refSize, err = readEstring(buffer, &val.Ref); err != nil {
+		return 0, err
+	}
+	if idSize, err = readObjectID(buffer, &val.ID); err != nil {
+		return 0, err
+	}
+
+	return refSize + idSize, nil
+}
+
 func readBoolean(buffer *bytelib.Buffer, val *bool) (numread int, err error) {
 	b, err := buffer.ReadByte()
 	if err != nil {
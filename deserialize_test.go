@@ -1,6 +1,7 @@
 package ezbson
 
 import (
+	"math"
 	"testing"
 	timelib "time"
 
@@ -1196,3 +1197,715 @@ func TestDeserializeIntArray(t *testing.T) {
 		return
 	}
 }
+
+func TestDeserializeNull(t *testing.T) {
+	kMarshalled := []byte{
+		0x25, 0x00, 0x00, 0x00, // total document size
+		0x0a, // etype-null
+		'A', 'n', 'y', 0x00,
+		0x0a, // etype-null
+		'I', 'n', 't', 0x00,
+		0x0a, // etype-null
+		'M', 'a', 'p', 0x00,
+		0x0a, // etype-null
+		'P', 't', 'r', 0x00,
+		0x0a, // etype-null
+		'S', 'l', 'i', 'c', 'e', 0x00,
+		0x0a, // etype-null
+		'S', 't', 'r', 0x00,
+		0x00, // doc-end
+	}
+
+	type NullStruct struct {
+		Any   any
+		Int   int64
+		Map   map[string]any
+		Ptr   *int64
+		Slice []any
+		Str   string
+	}
+
+	num := int64(5)
+	actual := NullStruct{
+		Any:   "something",
+		Int:   5,
+		Map:   map[string]any{"a": "b"},
+		Ptr:   &num,
+		Slice: []any{"a"},
+		Str:   "something",
+	}
+
+	if err := Unmarshal(kMarshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.Nil(t, deep.Equal(NullStruct{}, actual)) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+
+	expectedMap := map[string]any{
+		"Any": nil, "Int": nil, "Map": nil, "Ptr": nil, "Slice": nil, "Str": nil,
+	}
+	if !assert.Equal(t, expectedMap, asMap) {
+		return
+	}
+}
+
+func TestDeserializeNullInSlice(t *testing.T) {
+	kMarshalled := []byte{
+		0x17, 0x00, 0x00, 0x00, // total document size
+		0x04, // etype-array
+		'a', 0x00,
+		0x0f, 0x00, 0x00, 0x00,
+		0x0a, // etype-null
+		'0', 0x00,
+		0x10, // etype-int32
+		'1', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x00, // end-slice
+		0x00, // doc-end
+	}
+
+	asAny := make(map[string][]any)
+	if err := Unmarshal(kMarshalled, &asAny); !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Equal(t, map[string][]any{"a": {nil, int32(1)}}, asAny) {
+		return
+	}
+
+	asInt32 := make(map[string][]int32)
+	if err := Unmarshal(kMarshalled, &asInt32); !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Equal(t, map[string][]int32{"a": {0, 1}}, asInt32) {
+		return
+	}
+}
+
+func TestDeserializeNumericKinds(t *testing.T) {
+	marshalled, err := Marshal(map[string]any{
+		"F32": float64(1.5),
+		"F64": int64(3),
+		"I8":  int32(-8),
+		"I16": int64(-16),
+		"I32": int64(32),
+		"U":   int64(1 << 40),
+		"U8":  int32(8),
+		"U16": int32(16),
+		"U32": float64(1 << 31),
+		"U64": int64(1 << 62),
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	type NumericStruct struct {
+		F32 float32
+		F64 float64
+		I8  int8
+		I16 int16
+		I32 int32
+		U   uint
+		U8  uint8
+		U16 uint16
+		U32 uint32
+		U64 uint64
+	}
+
+	expected := NumericStruct{
+		F32: 1.5,
+		F64: 3,
+		I8:  -8,
+		I16: -16,
+		I32: 32,
+		U:   1 << 40,
+		U8:  8,
+		U16: 16,
+		U32: 1 << 31,
+		U64: 1 << 62,
+	}
+
+	actual := NumericStruct{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestDeserializeNumericOverflow(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  map[string]any
+		ptr  any
+	}{
+		{"int32->int8", map[string]any{"X": int32(128)}, &struct{ X int8 }{}},
+		{"int64->int32", map[string]any{"X": int64(1 << 31)}, &struct{ X int32 }{}},
+		{"negative->uint16", map[string]any{"X": int32(-1)}, &struct{ X uint16 }{}},
+		{"double->float32", map[string]any{"X": float64(1e300)}, &struct{ X float32 }{}},
+		{"fraction->int64", map[string]any{"X": float64(1.5)}, &struct{ X int64 }{}},
+		{"double->int64", map[string]any{"X": float64(1e19)}, &struct{ X int64 }{}},
+		{"int64->float64", map[string]any{"X": int64(1<<53 + 1)}, &struct{ X float64 }{}},
+		{"int64->float64 (max)", map[string]any{"X": int64(math.MaxInt64)}, &struct{ X float64 }{}},
+		{"int32->float32", map[string]any{"X": int32(1<<24 + 1)}, &struct{ X float32 }{}},
+		{"int32->map[string]uint8", map[string]any{"X": int32(256)}, &map[string]uint8{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			marshalled, err := Marshal(test.doc)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			err = Unmarshal(marshalled, test.ptr)
+			if !assert.NotNil(t, err) {
+				return
+			}
+			assert.Contains(t, err.Error(), "{X}")
+		})
+	}
+}
+
+func TestDeserializeNamedTypes(t *testing.T) {
+	type NamedStruct struct {
+		S namedString
+		I namedInt
+		B namedBytes
+		M namedMap
+		A namedSlice
+	}
+
+	expected := NamedStruct{
+		S: "x",
+		I: 3,
+		B: namedBytes{0xff},
+		M: namedMap{"k": 7},
+		A: namedSlice{"y"},
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := NamedStruct{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+
+	marshalled, err = Marshal(expected.M)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asNamedMap := make(namedMap)
+	if err := Unmarshal(marshalled, &asNamedMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected.M, asNamedMap)
+}
+
+func TestDeserializeGoArrays(t *testing.T) {
+	type ArrayStruct struct {
+		C [3]float64
+		H [4]byte
+		A [2]any
+	}
+
+	expected := ArrayStruct{
+		C: [3]float64{1.5, 2.5, 3.5},
+		H: [4]byte{0xde, 0xad, 0xbe, 0xef},
+		A: [2]any{"x", int32(7)},
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := ArrayStruct{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDeserializeGoArraysOfMoreThan10Elements(t *testing.T) {
+	type ArrayStruct struct {
+		A [12]int32
+		S []string
+	}
+
+	expected := ArrayStruct{
+		A: [12]int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		S: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := ArrayStruct{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDeserializeGoArraysLengthMismatch(t *testing.T) {
+	marshalled, err := Marshal(map[string]any{
+		"C": []int32{1, 2, 3},
+		"H": []byte{0xaa, 0xbb},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.NotNil(t, Unmarshal(marshalled, &struct {
+		C [2]int32
+		H [2]byte
+	}{}))
+	assert.NotNil(t, Unmarshal(marshalled, &struct {
+		C [4]int32
+		H [2]byte
+	}{}))
+	assert.NotNil(t, Unmarshal(marshalled, &struct {
+		C [3]int32
+		H [3]byte
+	}{}))
+
+	dec := Decoder{AllowArrayLengthMismatch: true}
+
+	truncated := struct {
+		C [2]int32
+		H [1]byte
+	}{}
+	if err := dec.Unmarshal(marshalled, &truncated); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, [2]int32{1, 2}, truncated.C)
+	assert.Equal(t, [1]byte{0xaa}, truncated.H)
+
+	padded := struct {
+		C [4]int32
+		H [3]byte
+	}{}
+	if err := dec.Unmarshal(marshalled, &padded); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, [4]int32{1, 2, 3, 0}, padded.C)
+	assert.Equal(t, [3]byte{0xaa, 0xbb, 0x00}, padded.H)
+}
+
+func TestDeserializePointers(t *testing.T) {
+	type Inner struct {
+		X int32
+	}
+
+	type PointerStruct struct {
+		Int    *int64
+		Time   *timelib.Time
+		Inner  *Inner
+		Double **Inner
+		Map    map[string]*Inner
+		Slice  []*int32
+		Any    *any
+	}
+
+	kTime := timelib.Date(2006, 1, 2, 15, 4, 5, 0, timelib.UTC)
+
+	marshalled, err := Marshal(map[string]any{
+		"Int":    int64(1),
+		"Time":   kTime,
+		"Inner":  map[string]any{"X": int32(2)},
+		"Double": map[string]any{"X": int32(3)},
+		"Map":    map[string]any{"a": map[string]any{"X": int32(4)}, "b": nil},
+		"Slice":  []any{int32(5), nil},
+		"Any":    "six",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := PointerStruct{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.NotNil(t, actual.Int) || !assert.NotNil(t, actual.Time) || !assert.NotNil(t, actual.Inner) ||
+		!assert.NotNil(t, actual.Double) || !assert.NotNil(t, *actual.Double) || !assert.NotNil(t, actual.Any) {
+		return
+	}
+	assert.Equal(t, int64(1), *actual.Int)
+	assert.Equal(t, kTime, *actual.Time)
+	assert.Equal(t, Inner{X: 2}, *actual.Inner)
+	assert.Equal(t, Inner{X: 3}, **actual.Double)
+	assert.Equal(t, map[string]*Inner{"a": {X: 4}, "b": nil}, actual.Map)
+	five := int32(5)
+	assert.Equal(t, []*int32{&five, nil}, actual.Slice)
+	assert.Equal(t, any("six"), *actual.Any)
+}
+
+func TestDeserializePointersReuseAndNull(t *testing.T) {
+	type Inner struct {
+		X int32
+		Y int32
+	}
+
+	marshalled, err := Marshal(map[string]any{
+		"Inner": map[string]any{"X": int32(1)},
+		"Int":   nil,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	existing := &Inner{X: 10, Y: 20}
+	num := int64(5)
+	actual := struct {
+		Inner *Inner
+		Int   *int64
+	}{Inner: existing, Int: &num}
+
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+
+	assert.True(t, existing == actual.Inner) // The existing pointee is reused (and Y is untouched)
+	assert.Equal(t, Inner{X: 1, Y: 20}, *existing)
+	assert.Nil(t, actual.Int)
+	assert.Equal(t, int64(5), num)
+}
+
+func TestDeserializeMapKeys(t *testing.T) {
+	type MapKeysStruct struct {
+		I map[int64]string
+		U map[uint8]string
+		T map[textKey]string
+	}
+
+	expected := MapKeysStruct{
+		I: map[int64]string{-1: "a", 1 << 40: "b"},
+		U: map[uint8]string{255: "c"},
+		T: map[textKey]string{{A: 1, B: 2}: "d"},
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := MapKeysStruct{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDeserializeMapKeysBadKey(t *testing.T) {
+	marshalled, err := Marshal(map[string]any{"abc": "x", "256": "y"})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asIntMap := make(map[int]string)
+	assert.NotNil(t, Unmarshal(marshalled, &asIntMap))
+
+	asUint8Map := make(map[uint8]string)
+	assert.NotNil(t, Unmarshal(marshalled, &asUint8Map))
+
+	asTextMap := make(map[textKey]string)
+	err = Unmarshal(marshalled, &asTextMap)
+	if !assert.NotNil(t, err) {
+		return
+	}
+	assert.Contains(t, err.Error(), "bad textKey")
+}
+
+func TestDeserializeEmbeddedStructs(t *testing.T) {
+	type Named struct {
+		Y int32
+	}
+	type Outer struct {
+		*EmbeddedInner
+		N     Named          `bson:",inline"`
+		Extra map[string]any `bson:",inline"`
+	}
+
+	marshalled, err := Marshal(map[string]any{
+		"X": int32(1),
+		"Y": int32(2),
+		"Z": "three",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := Outer{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+
+	expected := Outer{
+		EmbeddedInner: &EmbeddedInner{X: 1},
+		N:             Named{Y: 2},
+		Extra:         map[string]any{"Z": "three"},
+	}
+	assert.Equal(t, expected, actual)
+
+	// Without an inline map, unknown fields are skipped (or return an error with DisallowUnknownFields)
+	withoutInlineMap := struct{ EmbeddedInner }{}
+	if err := Unmarshal(marshalled, &withoutInlineMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, int32(1), withoutInlineMap.X)
+	assert.NotNil(t, (&Decoder{DisallowUnknownFields: true}).Unmarshal(marshalled, &struct{ EmbeddedInner }{}))
+}
+
+func TestDeserializeUnexportedFields(t *testing.T) {
+	type S struct {
+		Public int32
+		secret string
+	}
+
+	marshalled, err := Marshal(map[string]any{"Public": int32(1), "secret": "x"})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := S{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, S{Public: 1}, actual)
+
+	err = (&Decoder{DisallowUnexportedFields: true}).Unmarshal(marshalled, &S{})
+	if !assert.NotNil(t, err) {
+		return
+	}
+	assert.Contains(t, err.Error(), "{secret}")
+
+	// An element named like an unexported field is still an unknown element
+	err = (&Decoder{DisallowUnknownFields: true}).Unmarshal(marshalled, &S{})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "{secret}")
+	}
+
+	// and it is collected by the remainder, so it isn't lost on the round trip
+	type WithRest struct {
+		ID   string `bson:"id"`
+		name string
+		Rest map[string]any `bson:",inline"`
+	}
+
+	expected := map[string]any{"id": "1", "name": "bob", "age": int32(3)}
+	marshalled, err = Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	withRest := WithRest{}
+	if err := (&Decoder{DisallowUnexportedFields: true}).Unmarshal(marshalled, &withRest); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, WithRest{ID: "1", Rest: map[string]any{"name": "bob", "age": int32(3)}}, withRest)
+
+	remarshalled, err := Marshal(withRest)
+	if !assert.Nil(t, err) {
+		return
+	}
+	actualMap := make(map[string]any)
+	if assert.Nil(t, Unmarshal(remarshalled, &actualMap)) {
+		assert.Equal(t, expected, actualMap)
+	}
+}
+
+func TestDeserializeTags(t *testing.T) {
+	type Tagged struct {
+		CreatedAt timelib.Time `bson:"created_at,omitempty"`
+		Name      string       `bson:"name"`
+		Count     int64        `bson:"count,minsize"`
+		Skipped   string       `bson:"-"`
+	}
+
+	expected := Tagged{
+		CreatedAt: timelib.Date(2006, 1, 2, 15, 4, 5, 0, timelib.UTC),
+		Name:      "x",
+		Count:     7,
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(marshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"created_at": expected.CreatedAt, "name": "x", "count": int32(7)}, asMap)
+
+	actual := Tagged{Skipped: "untouched"}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	expected.Skipped = "untouched"
+	assert.Equal(t, expected, actual)
+}
+
+func TestDeserializeNamingStrategy(t *testing.T) {
+	type S struct {
+		UserID    int32
+		CreatedAt string
+		Tagged    int32 `bson:"TAG"`
+	}
+
+	marshalled, err := Marshal(map[string]any{"userId": int32(1), "createdAt": "now", "TAG": int32(2)})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := S{}
+	if err := (&Decoder{NamingStrategy: NamingCamelCase}).Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, S{UserID: 1, CreatedAt: "now", Tagged: 2}, actual)
+
+	// Without a naming strategy, the names only match case-insensitively
+	assert.NotNil(t, (&Decoder{DisallowUnknownFields: true}).Unmarshal(marshalled, &S{}))
+
+	actual = S{}
+	if err := (&Decoder{CaseInsensitive: true}).Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, S{UserID: 1, CreatedAt: "now", Tagged: 2}, actual)
+}
+
+func TestDeserializeUnknownFields(t *testing.T) {
+	unknown := map[string]any{
+		"double":   1.5,
+		"string":   "s",
+		"document": map[string]any{"a": int32(1)},
+		"array":    []any{"a", int64(2)},
+		"binary":   []byte{1, 2, 3},
+		"oid":      ObjectID{1},
+		"bool":     true,
+		"time":     timelib.Date(2006, 1, 2, 15, 4, 5, 0, timelib.UTC),
+		"null":     nil,
+		"regex":    Regex{Pattern: "a.*", Options: "i"},
+		"js":       JavaScript("return 1"),
+		"cws":      CodeWithScope{Code: "return x", Scope: map[string]any{"x": int32(1)}},
+		"int32":    int32(3),
+		"ts":       Timestamp{T: 1, I: 2},
+		"int64":    int64(4),
+		"dec":      NewDecimal128(1, 2),
+		"min":      MinKey{},
+		"max":      MaxKey{},
+		"dbptr":    DBPointer{Ref: "coll", ID: ObjectID{2}},
+		"symbol":   Symbol("sym"),
+		"undef":    Undefined{},
+		"Known":    "k",
+	}
+
+	marshalled, err := (&Encoder{AllowDeprecatedTypes: true}).Marshal(unknown)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	type S struct {
+		Known string
+	}
+
+	actual := S{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, S{Known: "k"}, actual)
+
+	err = (&Decoder{DisallowUnknownFields: true}).Unmarshal(marshalled, &S{})
+	if !assert.NotNil(t, err) {
+		return
+	}
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestDeserializeDocumentIntoNonDocumentStruct(t *testing.T) {
+	marshalled, err := Marshal(map[string]any{"V": map[string]any{"X": int32(1)}})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	preset := timelib.Date(2006, 1, 2, 15, 4, 5, 0, timelib.UTC)
+	asTime := struct{ V timelib.Time }{V: preset}
+	assert.NotNil(t, Unmarshal(marshalled, &asTime))
+	assert.Equal(t, preset, asTime.V)
+
+	for _, ptr := range []any{
+		&struct{ V Decimal128 }{},
+		&struct{ V Timestamp }{},
+		&struct{ V Regex }{},
+		&struct{ V Binary }{},
+		&struct{ V DBPointer }{},
+		&struct{ V *timelib.Time }{},
+	} {
+		assert.NotNil(t, Unmarshal(marshalled, ptr), "%T", ptr)
+	}
+
+	// Other structs are still decoded from documents
+	asStruct := struct{ V struct{ X int32 } }{}
+	if assert.Nil(t, Unmarshal(marshalled, &asStruct)) {
+		assert.Equal(t, int32(1), asStruct.V.X)
+	}
+}
+
+func TestDeserializeRemainder(t *testing.T) {
+	type WithMap struct {
+		Known string
+		Rest  map[string]any `bson:",remainder"`
+	}
+	type WithRaw struct {
+		Known string
+		Rest  Raw `bson:",remainder"`
+	}
+
+	marshalled, err := Marshal(map[string]any{"Known": "k", "a": int32(1), "b": []any{"x"}})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	withMap := WithMap{}
+	if err := Unmarshal(marshalled, &withMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, WithMap{Known: "k", Rest: map[string]any{"a": int32(1), "b": []any{"x"}}}, withMap)
+
+	withRaw := WithRaw{}
+	if err := Unmarshal(marshalled, &withRaw); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "k", withRaw.Known)
+
+	rest := make(map[string]any)
+	if err := Unmarshal(withRaw.Rest, &rest); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"a": int32(1), "b": []any{"x"}}, rest)
+
+	// The leftovers survive a round-trip
+	for _, val := range []any{withMap, withRaw} {
+		remarshalled, err := Marshal(val)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, marshalled, remarshalled)
+	}
+}
@@ -0,0 +1,14 @@
+package ezbson
+
+// JavaScript is the BSON javascript code type (etype 13).
+// It is serialized like a string.
+type JavaScript string
+
+// CodeWithScope is the BSON code with scope type (etype 15): javascript code along with a mapping
+// from identifiers to values, representing the scope in which the code should be evaluated.
+//
+// A nil Scope is serialized as an empty document.
+type CodeWithScope struct {
+	Code  JavaScript
+	Scope map[string]any
+}
@@ -0,0 +1,106 @@
+package ezbson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJavaScriptMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x15, 0x00, 0x00, 0x00, // total document size
+		0x0d, // etype-javascript
+		'J', 0x00,
+		0x09, 0x00, 0x00, 0x00,
+		'r', 'e', 't', 'u', 'r', 'n', ' ', '1', 0x00,
+		0x00,
+	}
+
+	marshalled, err := Marshal(map[string]any{"J": JavaScript("return 1")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"J": JavaScript("return 1")}, asMap)
+
+	asStruct := struct {
+		J JavaScript
+	}{}
+	if err := Unmarshal(kMarshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, JavaScript("return 1"), asStruct.J)
+
+	asString := make(map[string]string)
+	if err := Unmarshal(kMarshalled, &asString); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{"J": "return 1"}, asString)
+}
+
+func TestCodeWithScopeMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x25, 0x00, 0x00, 0x00, // total document size
+		0x0f, // etype-code-with-scope
+		'C', 0x00,
+		0x1d, 0x00, 0x00, 0x00, // code_w_s size
+		0x09, 0x00, 0x00, 0x00,
+		'r', 'e', 't', 'u', 'r', 'n', ' ', 'x', 0x00,
+		0x0c, 0x00, 0x00, 0x00, // scope size
+		0x10, // etype-int32
+		'x', 0x00,
+		0x05, 0x00, 0x00, 0x00,
+		0x00, // scope end
+		0x00,
+	}
+
+	cws := CodeWithScope{
+		Code:  "return x",
+		Scope: map[string]any{"x": int32(5)},
+	}
+
+	marshalled, err := Marshal(map[string]any{"C": cws})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"C": cws}, asMap)
+
+	asStruct := struct {
+		C CodeWithScope
+	}{}
+	if err := Unmarshal(kMarshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, cws, asStruct.C)
+}
+
+func TestCodeWithScopeBadSize(t *testing.T) {
+	kMarshalled := []byte{
+		0x25, 0x00, 0x00, 0x00, // total document size
+		0x0f, // etype-code-with-scope
+		'C', 0x00,
+		0x1e, 0x00, 0x00, 0x00, // code_w_s size (wrong, should be 0x1d)
+		0x09, 0x00, 0x00, 0x00,
+		'r', 'e', 't', 'u', 'r', 'n', ' ', 'x', 0x00,
+		0x0c, 0x00, 0x00, 0x00, // scope size
+		0x10, // etype-int32
+		'x', 0x00,
+		0x05, 0x00, 0x00, 0x00,
+		0x00, // scope end
+		0x00,
+	}
+
+	asMap := make(map[string]any)
+	assert.NotNil(t, Unmarshal(kMarshalled, &asMap))
+}
@@ -0,0 +1,215 @@
+package ezbson
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// Marshaler is implemented by types that marshal themselves into a BSON document.
+// MarshalBSON must return a complete document (including its size prefix and terminator).
+type Marshaler interface {
+	MarshalBSON() ([]byte, error)
+}
+
+// ValueMarshaler is implemented by types that marshal themselves into a BSON value of any type.
+// MarshalBSONValue returns the etype of the value (e.g. 0x02 for a string), and the marshalled evalue.
+//
+// When a type implements both ValueMarshaler and Marshaler, ValueMarshaler is used.
+type ValueMarshaler interface {
+	MarshalBSONValue() (etype byte, data []byte, err error)
+}
+
+// Unmarshaler is implemented by types that unmarshal a BSON document into themselves.
+// data is only valid during the call to UnmarshalBSON, so it must be copied if it is retained.
+//
+// UnmarshalBSON is not called for a BSON null (or undefined), which sets the value to its zero-value as usual.
+type Unmarshaler interface {
+	UnmarshalBSON(data []byte) error
+}
+
+// ValueUnmarshaler is implemented by types that unmarshal a BSON value of any type into themselves.
+// data is only valid during the call to UnmarshalBSONValue, so it must be copied if it is retained.
+//
+// When a type implements both ValueUnmarshaler and Unmarshaler, ValueUnmarshaler is used.
+type ValueUnmarshaler interface {
+	UnmarshalBSONValue(etype byte, data []byte) error
+}
+
+var (
+	binaryMarshalerRtype   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerRtype = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	marshalerRtype        = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	valueMarshalerRtype   = reflect.TypeOf((*ValueMarshaler)(nil)).Elem()
+	unmarshalerRtype      = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	valueUnmarshalerRtype = reflect.TypeOf((*ValueUnmarshaler)(nil)).Elem()
+)
+
+// nativeRtypes are the types that have their own BSON representation (matched exactly by getEtype and readEvalue).
+// They aren't marshalled with their encoding.TextMarshaler or encoding.BinaryMarshaler methods (e.g. UUID and time.Time).
+var nativeRtypes = map[reflect.Type]bool{
+	reflect.TypeOf(Binary{}):         true,
+	reflect.TypeOf(UUID{}):           true,
+	reflect.TypeOf(ObjectID{}):       true,
+	reflect.TypeOf(time.Time{}):      true,
+	reflect.TypeOf(Regex{}):          true,
+	reflect.TypeOf(&regexp.Regexp{}): true,
+	reflect.TypeOf(JavaScript("")):   true,
+	reflect.TypeOf(CodeWithScope{}):  true,
+	reflect.TypeOf(Timestamp{}):      true,
+	reflect.TypeOf(Decimal128{}):     true,
+	reflect.TypeOf(Raw{}):            true,
+	reflect.TypeOf(MinKey{}):         true,
+	reflect.TypeOf(MaxKey{}):         true,
+	reflect.TypeOf(Undefined{}):      true,
+	reflect.TypeOf(DBPointer{}):      true,
+	reflect.TypeOf(Symbol("")):       true,
+}
+
+// rawValue is a marshalled evalue (e.g. as returned by a ValueMarshaler), which is appended as-is.
+type rawValue struct {
+	et   etype
+	data []byte
+}
+
+// resolveMarshaler calls the MarshalBSONValue (or MarshalBSON) method of val, and returns its result as a rawValue.
+// Otherwise, the encoding.TextMarshaler (as a string) and encoding.BinaryMarshaler (as binary) fallbacks are used.
+// If val doesn't implement any of them, it is returned as-is.
+func resolveMarshaler(val any) (any, error) {
+	switch marshaler := findMarshaler(val, isMarshalerRtype).(type) {
+	case ValueMarshaler:
+		et, data, err := marshaler.MarshalBSONValue()
+		if err != nil {
+			return nil, fmt.Errorf("%T.MarshalBSONValue: %w", marshaler, err)
+		}
+
+		result := rawValue{et: etype(et), data: data}
+		if err = result.validate(); err != nil {
+			return nil, fmt.Errorf("%T.MarshalBSONValue: %w", marshaler, err)
+		}
+		return result, nil
+
+	case Marshaler:
+		data, err := marshaler.MarshalBSON()
+		if err != nil {
+			return nil, fmt.Errorf("%T.MarshalBSON: %w", marshaler, err)
+		}
+
+		result := rawValue{et: kEtypeDocument, data: data}
+		if err = result.validate(); err != nil {
+			return nil, fmt.Errorf("%T.MarshalBSON: %w", marshaler, err)
+		}
+		return result, nil
+	}
+
+	switch marshaler := findMarshaler(val, isEncodingMarshalerRtype).(type) {
+	case encoding.TextMarshaler:
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("%T.MarshalText: %w", marshaler, err)
+		}
+
+		data, err := appendString(nil, string(text))
+		return rawValue{et: kEtypeString, data: data}, err
+
+	case encoding.BinaryMarshaler:
+		bin, err := marshaler.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("%T.MarshalBinary: %w", marshaler, err)
+		}
+
+		data, err := appendBinary(nil, Binary{Subtype: BinarySubtypeGeneric, Data: bin})
+		return rawValue{et: kEtypeBinary, data: data}, err
+
+	default:
+		return val, nil
+	}
+}
+
+// findMarshaler returns the value whose type satisfies isMarshaler: either val, the pointee of val
+// (pointers are dereferenced), or a pointer to a copy of it (for pointer receivers). nil is returned if there is none.
+func findMarshaler(val any, isMarshaler func(reflect.Type) bool) any {
+	for !isNull(val) {
+		if isMarshaler(reflect.TypeOf(val)) {
+			return val
+		}
+
+		rvalue := reflect.ValueOf(val)
+		if rvalue.Kind() != reflect.Pointer {
+			if !isMarshaler(reflect.PointerTo(rvalue.Type())) {
+				return nil
+			}
+
+			ptr := reflect.New(rvalue.Type())
+			ptr.Elem().Set(rvalue)
+			return ptr.Interface()
+		}
+
+		val = rvalue.Elem().Interface()
+	}
+
+	return nil
+}
+
+func isMarshalerRtype(rtype reflect.Type) bool {
+	return rtype.Implements(valueMarshalerRtype) || rtype.Implements(marshalerRtype)
+}
+
+// isEncodingMarshalerRtype reports whether rtype implements encoding.TextMarshaler or encoding.BinaryMarshaler,
+// and is not (a pointer to) one of the nativeRtypes.
+func isEncodingMarshalerRtype(rtype reflect.Type) bool {
+	if nativeRtypes[rtype] || (rtype.Kind() == reflect.Pointer && nativeRtypes[rtype.Elem()]) {
+		return false
+	}
+
+	return rtype.Implements(textMarshalerRtype) || rtype.Implements(binaryMarshalerRtype)
+}
+
+// isEncodingUnmarshalerRtype reports whether a pointer to rtype implements encoding.TextUnmarshaler (if et is a string)
+// or encoding.BinaryUnmarshaler (if et is binary), and rtype is not one of the nativeRtypes.
+func isEncodingUnmarshalerRtype(rtype reflect.Type, et etype) bool {
+	if nativeRtypes[rtype] {
+		return false
+	}
+
+	switch et {
+	case kEtypeString:
+		return reflect.PointerTo(rtype).Implements(textUnmarshalerRtype)
+	case kEtypeBinary:
+		return reflect.PointerTo(rtype).Implements(binaryUnmarshalerRtype)
+	default:
+		return false
+	}
+}
+
+// isUnmarshalerRtype reports whether a pointer to rtype implements ValueUnmarshaler or Unmarshaler.
+func isUnmarshalerRtype(rtype reflect.Type) bool {
+	ptrRtype := reflect.PointerTo(rtype)
+	return ptrRtype.Implements(valueUnmarshalerRtype) || ptrRtype.Implements(unmarshalerRtype)
+}
+
+// validate checks that data is a well-formed evalue of type et: that its size matches et (or its size prefixes),
+// that strings are null-terminated, and that documents and arrays are well-formed (see Raw.Validate).
+func (rv rawValue) validate() error {
+	size, err := evalueSize(rv.data, rv.et)
+	if err != nil {
+		return err
+	}
+	if size != len(rv.data) {
+		return fmt.Errorf("evalue of etype %v has %v bytes, but its size is %v", rv.et, len(rv.data), size)
+	}
+
+	switch rv.et {
+	case kEtypeDocument, kEtypeArray:
+		return Raw(rv.data).Validate()
+	case kEtypeString, kEtypeJavascriptCode, kEtypeSymbol:
+		if size == kInt32Size || rv.data[size-1] != kNullTerminator {
+			return fmt.Errorf("string evalue of etype %v is not null-terminated", rv.et)
+		}
+	}
+
+	return nil
+}
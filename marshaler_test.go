@@ -0,0 +1,322 @@
+package ezbson
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// money is marshalled as a BSON string of cents (e.g. "1250" for 12.50)
+type money struct {
+	Cents int64
+}
+
+func (m money) MarshalBSONValue() (byte, []byte, error) {
+	return appendStringValue(strconv.FormatInt(m.Cents, 10))
+}
+
+func (m *money) UnmarshalBSONValue(et byte, data []byte) error {
+	if etype(et) != kEtypeString {
+		return fmt.Errorf("unexpected etype %v", et)
+	}
+
+	var s string
+	if _, err := readEstring(bytes.NewBuffer(data), &s); err != nil {
+		return err
+	}
+
+	cents, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	m.Cents = cents
+	return nil
+}
+
+func appendStringValue(s string) (byte, []byte, error) {
+	data, err := appendString(nil, s)
+	return byte(kEtypeString), data, err
+}
+
+// geoPoint is marshalled as a GeoJSON document (with a pointer receiver)
+type geoPoint struct {
+	Lng, Lat float64
+}
+
+func (p *geoPoint) MarshalBSON() ([]byte, error) {
+	return Marshal(map[string]any{"type": "Point", "coordinates": []float64{p.Lng, p.Lat}})
+}
+
+func (p *geoPoint) UnmarshalBSON(data []byte) error {
+	var doc struct {
+		Type        string     `bson:"type"`
+		Coordinates [2]float64 `bson:"coordinates"`
+	}
+	if err := Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	p.Lng, p.Lat = doc.Coordinates[0], doc.Coordinates[1]
+	return nil
+}
+
+type badMarshaler struct{}
+
+func (badMarshaler) MarshalBSONValue() (byte, []byte, error) {
+	return byte(kEtypeInt32), []byte{0x01, 0x02}, nil // too short
+}
+
+func TestMarshalerRoundTrip(t *testing.T) {
+	type Shop struct {
+		Price    money
+		Discount *money
+		Location geoPoint
+		History  []money
+		Branches map[string]*geoPoint
+	}
+
+	expected := Shop{
+		Price:    money{Cents: 1250},
+		Discount: &money{Cents: 100},
+		Location: geoPoint{Lng: 1.5, Lat: 2.5},
+		History:  []money{{Cents: 1}, {Cents: 2}},
+		Branches: map[string]*geoPoint{"north": {Lng: 3, Lat: 4}},
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(marshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "1250", asMap["Price"])
+	assert.Equal(t, map[string]any{"type": "Point", "coordinates": []any{1.5, 2.5}}, asMap["Location"])
+
+	actual := Shop{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestMarshalerTopLevel(t *testing.T) {
+	marshalled, err := Marshal(&geoPoint{Lng: 1, Lat: 2})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := geoPoint{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, geoPoint{Lng: 1, Lat: 2}, actual)
+
+	// A top-level ValueMarshaler must marshal into a document
+	_, err = Marshal(money{Cents: 1})
+	assert.NotNil(t, err)
+}
+
+func TestMarshalerErrors(t *testing.T) {
+	_, err := Marshal(map[string]any{"bad": badMarshaler{}})
+	assert.NotNil(t, err)
+
+	// Unmarshaler only supports documents
+	marshalled, err := Marshal(map[string]any{"Location": "somewhere"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotNil(t, Unmarshal(marshalled, &struct{ Location geoPoint }{}))
+
+	// Errors returned by UnmarshalBSONValue are propagated
+	marshalled, err = Marshal(map[string]any{"Price": "not a number"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotNil(t, Unmarshal(marshalled, &struct{ Price money }{}))
+}
+
+func TestRawValueValidate(t *testing.T) {
+	assert.Nil(t, rawValue{et: kEtypeInt32, data: []byte{1, 0, 0, 0}}.validate())
+	assert.Nil(t, rawValue{et: kEtypeNull}.validate())
+	assert.NotNil(t, rawValue{et: kEtypeInt32, data: []byte{1, 0, 0, 0, 0}}.validate())
+	assert.NotNil(t, rawValue{et: kEtypeString, data: []byte{5, 0, 0, 0, 'a', 0}}.validate())
+	assert.NotNil(t, rawValue{et: kEtypeDocument, data: []byte{5, 0, 0, 0, 1}}.validate())
+	assert.NotNil(t, rawValue{et: kEtypeDone}.validate())
+
+	assert.Nil(t, rawValue{et: kEtypeString, data: []byte{3, 0, 0, 0, 'a', 'b', 0}}.validate())
+	assert.NotNil(t, rawValue{et: kEtypeString, data: []byte{2, 0, 0, 0, 'a', 'b'}}.validate())       // not null-terminated
+	assert.NotNil(t, rawValue{et: kEtypeSymbol, data: []byte{0, 0, 0, 0}}.validate())                 // no null terminator at all
+	assert.NotNil(t, rawValue{et: kEtypeJavascriptCode, data: []byte{1, 0, 0, 0, 'a'}}.validate())    // not null-terminated
+	assert.NotNil(t, rawValue{et: kEtypeDocument, data: []byte{7, 0, 0, 0, 0x99, 0, 0}}.validate())   // invalid etype
+	assert.NotNil(t, rawValue{et: kEtypeArray, data: []byte{8, 0, 0, 0, 0x10, 'x', 0, 0}}.validate()) // int32 with no value
+	assert.NotNil(t, rawValue{et: kEtypeDocument, data: []byte{
+		0x15, 0, 0, 0,
+		0x03, 'd', 0, // an embedded document with an unterminated string
+		0x0d, 0, 0, 0,
+		0x02, 's', 0, 1, 0, 0, 0, 'a',
+		0,
+		0,
+	}}.validate())
+	assert.Nil(t, rawValue{et: kEtypeDocument, data: []byte{
+		0x16, 0, 0, 0,
+		0x04, 'a', 0,
+		0x0e, 0, 0, 0,
+		0x02, '0', 0, 2, 0, 0, 0, 'a', 0,
+		0,
+		0,
+	}}.validate())
+}
+
+// level is an enum that is marshalled as text
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	switch l {
+	case 0:
+		return []byte("low"), nil
+	case 1:
+		return []byte("high"), nil
+	default:
+		return nil, fmt.Errorf("bad level %d", int(l))
+	}
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return fmt.Errorf("bad level %q", text)
+	}
+	return nil
+}
+
+// checksum is marshalled as binary (with a pointer receiver)
+type checksum struct {
+	sum uint16
+}
+
+func (c *checksum) MarshalBinary() ([]byte, error) {
+	return []byte{byte(c.sum >> 8), byte(c.sum)}, nil
+}
+
+func (c *checksum) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("bad checksum length %v", len(data))
+	}
+	c.sum = uint16(data[0])<<8 | uint16(data[1])
+	return nil
+}
+
+// both implements both ValueMarshaler and TextMarshaler (ValueMarshaler takes precedence)
+type both struct{}
+
+func (both) MarshalBSONValue() (byte, []byte, error) {
+	return byte(kEtypeBoolean), []byte{0x01}, nil
+}
+
+func (both) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
+func TestEncodingMarshalerFallbacks(t *testing.T) {
+	type S struct {
+		Level    level
+		Levels   []level
+		Checksum checksum
+		Addr     netip.Addr
+		Both     both
+		Time     time.Time
+		UUID     UUID
+	}
+
+	expected := S{
+		Level:    1,
+		Levels:   []level{0, 1},
+		Checksum: checksum{sum: 0xabcd},
+		Addr:     netip.MustParseAddr("10.0.0.1"),
+		Time:     time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		UUID:     UUID{1},
+	}
+
+	marshalled, err := Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(marshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{
+		"Level":    "high",
+		"Levels":   []any{"low", "high"},
+		"Checksum": []byte{0xab, 0xcd},
+		"Addr":     "10.0.0.1",
+		"Both":     true,
+		"Time":     expected.Time, // Native types keep their BSON representation
+		"UUID":     expected.UUID,
+	}, asMap)
+
+	marshalled, err = Marshal(map[string]any{
+		"Level":    "high",
+		"Levels":   []any{"low", "high"},
+		"Checksum": []byte{0xab, 0xcd},
+		"Addr":     "10.0.0.1",
+		"Time":     expected.Time,
+		"UUID":     expected.UUID,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := S{}
+	if err := Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+
+	_, err = Marshal(map[string]any{"Level": level(2)})
+	assert.NotNil(t, err)
+
+	marshalled, err = Marshal(map[string]any{"Level": "medium"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotNil(t, Unmarshal(marshalled, &S{}))
+}
+
+// cents is an int64 kind that marshals itself as a string (so minsize must not apply to it)
+type cents int64
+
+func (c cents) MarshalBSONValue() (byte, []byte, error) {
+	return appendStringValue(strconv.FormatInt(int64(c), 10))
+}
+
+func TestMarshalerWithMinSize(t *testing.T) {
+	type S struct {
+		C     cents `bson:"c,minsize"`
+		Level level `bson:"level,minsize"`
+		Plain int64 `bson:"plain,minsize"`
+	}
+
+	marshalled, err := Marshal(S{C: 5, Level: 1, Plain: 7})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if assert.Nil(t, Unmarshal(marshalled, &asMap)) {
+		assert.Equal(t, map[string]any{"c": "5", "level": "high", "plain": int32(7)}, asMap)
+	}
+}
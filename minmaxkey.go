@@ -0,0 +1,9 @@
+package ezbson
+
+// MinKey is the BSON min key type (etype -1), which compares lower than all other BSON values.
+// It has no value (it is serialized as a zero-length element).
+type MinKey struct{}
+
+// MaxKey is the BSON max key type (etype 127), which compares higher than all other BSON values.
+// It has no value (it is serialized as a zero-length element).
+type MaxKey struct{}
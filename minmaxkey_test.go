@@ -0,0 +1,48 @@
+package ezbson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinMaxKeyMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x0d, 0x00, 0x00, 0x00, // total document size
+		0x7f, // etype-maxkey
+		'h', 'i', 0x00,
+		0xff, // etype-minkey
+		'l', 'o', 0x00,
+		0x00,
+	}
+
+	marshalled, err := Marshal(map[string]any{"lo": MinKey{}, "hi": MaxKey{}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"lo": MinKey{}, "hi": MaxKey{}}, asMap)
+
+	type Bounds struct {
+		Lo MinKey
+		Hi MaxKey
+	}
+	marshalled, err = Marshal(Bounds{})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asStruct := Bounds{}
+	if err := Unmarshal(marshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, Bounds{}, asStruct)
+
+	wrongType := make(map[string]MinKey)
+	assert.NotNil(t, Unmarshal(kMarshalled, &wrongType))
+}
@@ -0,0 +1,76 @@
+package ezbson
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NamingStrategy converts the golang name of an untagged struct field into its ename.
+// Fields with a `bson:"name"` tag always use the tag name.
+type NamingStrategy int
+
+const (
+	NamingGo        NamingStrategy = iota // "UserID" -> "UserID" (the default)
+	NamingLowerCase                       // "UserID" -> "userid"
+	NamingCamelCase                       // "UserID" -> "userId"
+	NamingSnakeCase                       // "UserID" -> "user_id"
+)
+
+func (ns NamingStrategy) apply(name string) string {
+	switch ns {
+	case NamingLowerCase:
+		return strings.ToLower(name)
+	case NamingCamelCase:
+		words := splitWords(name)
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				r, size := utf8.DecodeRuneInString(word) // The first letter may be more than one byte (e.g. "Ñ")
+				word = string(unicode.ToUpper(r)) + word[size:]
+			}
+			words[i] = word
+		}
+		return strings.Join(words, "")
+	case NamingSnakeCase:
+		words := splitWords(name)
+		for i, word := range words {
+			words[i] = strings.ToLower(word)
+		}
+		return strings.Join(words, "_")
+	default:
+		return name
+	}
+}
+
+// splitWords splits a golang identifier into words, keeping acronyms together
+// (e.g. "HTTPServerID2" -> ["HTTP", "Server", "ID2"]). Underscores also separate words.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	words := make([]string, 0)
+	start := 0
+
+	for i := range runes {
+		switch {
+		case runes[i] == '_':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case i == start:
+			continue
+		case unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]): // "userID" -> "user", "ID"
+			words = append(words, string(runes[start:i]))
+			start = i
+		case unicode.IsUpper(runes[i]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]): // "HTTPServer" -> "HTTP", "Server"
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
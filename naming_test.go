@@ -0,0 +1,48 @@
+package ezbson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := map[string][]string{
+		"Name":          {"Name"},
+		"UserID":        {"User", "ID"},
+		"HTTPServer":    {"HTTP", "Server"},
+		"ID":            {"ID"},
+		"Version2Name":  {"Version2", "Name"},
+		"created_at":    {"created", "at"},
+		"Snake_Case_ID": {"Snake", "Case", "ID"},
+	}
+
+	for name, expected := range tests {
+		assert.Equal(t, expected, splitWords(name), name)
+	}
+}
+
+func TestNamingStrategy(t *testing.T) {
+	tests := []struct {
+		naming   NamingStrategy
+		name     string
+		expected string
+	}{
+		{NamingGo, "UserID", "UserID"},
+		{NamingLowerCase, "UserID", "userid"},
+		{NamingCamelCase, "UserID", "userId"},
+		{NamingCamelCase, "HTTPServer", "httpServer"},
+		{NamingCamelCase, "Name", "name"},
+		{NamingSnakeCase, "UserID", "user_id"},
+		{NamingSnakeCase, "HTTPServer", "http_server"},
+		{NamingSnakeCase, "CreatedAt", "created_at"},
+		{NamingCamelCase, "UserÑame", "userÑame"},
+		{NamingCamelCase, "ÑameID", "ñameId"},
+		{NamingSnakeCase, "UserÑame", "user_ñame"},
+		{NamingLowerCase, "ÑAME", "ñame"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.naming.apply(test.name), test.name)
+	}
+}
@@ -0,0 +1,141 @@
+package ezbson
+
+import (
+	cryptorand "crypto/rand"
+	binlib "encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	timelib "time"
+)
+
+// ObjectID is the BSON ObjectId type (etype 7).
+//
+// The layout (all big-endian) is:
+//   - 4 bytes: seconds since the unix epoch.
+//   - 5 bytes: a random value, unique to the process.
+//   - 3 bytes: a counter, initialized to a random value.
+//
+// See https://www.mongodb.com/docs/manual/reference/method/ObjectId for more info.
+type ObjectID [12]byte
+
+const (
+	kObjectIDTimestampSize = 4
+	kObjectIDProcessSize   = 5
+	kObjectIDCounterSize   = 3
+)
+
+var (
+	objectIDProcessUnique = readObjectIDProcessUnique()
+	objectIDCounter       = readObjectIDCounterSeed()
+)
+
+func readObjectIDProcessUnique() [kObjectIDProcessSize]byte {
+	var b [kObjectIDProcessSize]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("ezbson: cannot initialize ObjectID process-unique value: %w", err))
+	}
+	return b
+}
+
+func readObjectIDCounterSeed() *atomic.Uint32 {
+	var b [4]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("ezbson: cannot initialize ObjectID counter: %w", err))
+	}
+
+	counter := &atomic.Uint32{}
+	counter.Store(binlib.BigEndian.Uint32(b[:]))
+	return counter
+}
+
+// NewObjectID generates a new ObjectID from the current time, the process-unique value and the counter.
+// It is safe for concurrent use.
+func NewObjectID() ObjectID {
+	return newObjectIDFromTime(timelib.Now())
+}
+
+func newObjectIDFromTime(t timelib.Time) ObjectID {
+	var id ObjectID
+
+	binlib.BigEndian.PutUint32(id[0:kObjectIDTimestampSize], uint32(t.Unix()))
+	copy(id[kObjectIDTimestampSize:], objectIDProcessUnique[:])
+
+	counter := objectIDCounter.Add(1)
+	id[9] = byte(counter >> 16)
+	id[10] = byte(counter >> 8)
+	id[11] = byte(counter)
+
+	return id
+}
+
+// ObjectIDFromHex parses a 24 character hex string (as returned by ObjectID.Hex) into an ObjectID.
+func ObjectIDFromHex(s string) (ObjectID, error) {
+	var id ObjectID
+
+	if len(s) != 2*len(id) {
+		return ObjectID{}, fmt.Errorf("invalid ObjectID hex length (%v)", len(s))
+	}
+
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return ObjectID{}, fmt.Errorf("invalid ObjectID hex (%v): %w", s, err)
+	}
+
+	return id, nil
+}
+
+// Hex returns the 24 character lowercase hex representation of id.
+func (id ObjectID) Hex() string {
+	return hex.EncodeToString(id[:])
+}
+
+// String implements fmt.Stringer.
+func (id ObjectID) String() string {
+	return fmt.Sprintf("ObjectID(%q)", id.Hex())
+}
+
+// IsZero reports whether id is the zero ObjectID.
+func (id ObjectID) IsZero() bool {
+	return id == ObjectID{}
+}
+
+// Timestamp returns the creation time encoded in the first 4 bytes of id (with a resolution of seconds).
+func (id ObjectID) Timestamp() timelib.Time {
+	secs := binlib.BigEndian.Uint32(id[0:kObjectIDTimestampSize])
+	return timelib.Unix(int64(secs), 0).UTC()
+}
+
+// MarshalText implements encoding.TextMarshaler (the hex representation is used).
+func (id ObjectID) MarshalText() ([]byte, error) {
+	return []byte(id.Hex()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler (the hex representation is expected).
+func (id *ObjectID) UnmarshalText(text []byte) error {
+	parsed, err := ObjectIDFromHex(string(text))
+	if err != nil {
+		return err
+	}
+
+	*id = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler (the hex representation is used, as a JSON string).
+func (id ObjectID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.Hex() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It expects a JSON string holding the hex representation.
+// A JSON null leaves id unchanged.
+func (id *ObjectID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("ObjectID must be a JSON string (got %s)", data)
+	}
+
+	return id.UnmarshalText(data[1 : len(data)-1])
+}
@@ -0,0 +1,114 @@
+package ezbson
+
+import (
+	"encoding/json"
+	"testing"
+	timelib "time"
+
+	"github.com/go-test/deep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectIDHex(t *testing.T) {
+	id, err := ObjectIDFromHex("5f1a2b3c4d5e6f7a8b9c0d1e")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected := ObjectID{0x5f, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x7a, 0x8b, 0x9c, 0x0d, 0x1e}
+	assert.Equal(t, expected, id)
+	assert.Equal(t, "5f1a2b3c4d5e6f7a8b9c0d1e", id.Hex())
+	assert.Equal(t, timelib.Unix(0x5f1a2b3c, 0).UTC(), id.Timestamp())
+
+	_, err = ObjectIDFromHex("5f1a2b3c")
+	assert.NotNil(t, err)
+
+	_, err = ObjectIDFromHex("zz1a2b3c4d5e6f7a8b9c0d1e")
+	assert.NotNil(t, err)
+}
+
+func TestNewObjectID(t *testing.T) {
+	before := timelib.Now().Truncate(timelib.Second)
+	id1 := NewObjectID()
+	id2 := NewObjectID()
+	after := timelib.Now()
+
+	assert.NotEqual(t, id1, id2)
+	assert.False(t, id1.Timestamp().Before(before))
+	assert.False(t, id1.Timestamp().After(after))
+
+	// Same process-unique bytes, consecutive counters.
+	assert.Equal(t, id1[4:9], id2[4:9])
+	counter1 := int(id1[9])<<16 | int(id1[10])<<8 | int(id1[11])
+	counter2 := int(id2[9])<<16 | int(id2[10])<<8 | int(id2[11])
+	assert.Equal(t, (counter1+1)&0xffffff, counter2)
+}
+
+func TestObjectIDJSON(t *testing.T) {
+	id, err := ObjectIDFromHex("5f1a2b3c4d5e6f7a8b9c0d1e")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	marshalled, err := json.Marshal(map[string]ObjectID{"_id": id})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, `{"_id":"5f1a2b3c4d5e6f7a8b9c0d1e"}`, string(marshalled))
+
+	unmarshalled := make(map[string]ObjectID)
+	if err := json.Unmarshal(marshalled, &unmarshalled); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, id, unmarshalled["_id"])
+}
+
+func TestObjectIDMarshalUnmarshal(t *testing.T) {
+	id := ObjectID{0x5f, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x7a, 0x8b, 0x9c, 0x0d, 0x1e}
+
+	kMarshalled := []byte{
+		0x16, 0x00, 0x00, 0x00, // total document size
+		0x07, // etype-objectid
+		'_', 'i', 'd', 0x00,
+		0x5f, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x7a, 0x8b, 0x9c, 0x0d, 0x1e,
+		0x00,
+	}
+
+	marshalled, err := Marshal(map[string]any{"_id": id})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, deep.Equal(map[string]any{"_id": id}, asMap))
+
+	asTypedMap := make(map[string]ObjectID)
+	if err := Unmarshal(kMarshalled, &asTypedMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]ObjectID{"_id": id}, asTypedMap)
+
+	asStruct := struct {
+		IDs []ObjectID
+		Any any
+	}{}
+	marshalled, err = Marshal(struct {
+		IDs []ObjectID
+		Any any
+	}{[]ObjectID{id, id}, id})
+	if !assert.Nil(t, err) {
+		return
+	}
+	if err := Unmarshal(marshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []ObjectID{id, id}, asStruct.IDs)
+	assert.Equal(t, id, asStruct.Any)
+
+	wrongType := make(map[string]string)
+	assert.NotNil(t, Unmarshal(kMarshalled, &wrongType))
+}
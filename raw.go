@@ -0,0 +1,81 @@
+package ezbson
+
+import (
+	bytelib "bytes"
+	binlib "encoding/binary"
+	"fmt"
+)
+
+// Raw is a marshalled BSON document, which is not decoded.
+//
+// A Raw is marshalled as-is (as an embedded document), and an embedded document is unmarshalled into a Raw
+// by copying its bytes. It is also supported as a `bson:",remainder"` field.
+type Raw []byte
+
+const kMinDocumentSize = kInt32Size + 1 // The size prefix, and the terminating kEtypeDone
+
+// Validate checks that raw is a well-formed document: that it has a valid size prefix and terminator,
+// and that each of its elements is well-formed (embedded documents and arrays are validated recursively).
+func (raw Raw) Validate() error {
+	_, _, err := raw.elements()
+	return err
+}
+
+// validateFrame checks that raw has a valid size prefix and terminator (the elements themselves are not validated).
+func (raw Raw) validateFrame() error {
+	if len(raw) < kMinDocumentSize {
+		return fmt.Errorf("raw document too short (%v bytes)", len(raw))
+	}
+
+	size := int32(binlib.LittleEndian.Uint32(raw))
+	if int(size) != len(raw) {
+		return fmt.Errorf("raw document size prefix (%v) does not match its length (%v)", size, len(raw))
+	}
+
+	if raw[len(raw)-1] != byte(kEtypeDone) {
+		return fmt.Errorf("raw document is not terminated")
+	}
+
+	return nil
+}
+
+// elements returns the marshalled elements of raw (without its size prefix and terminator),
+// and the enames of the elements in order. Each element is validated (see rawValue.validate).
+func (raw Raw) elements() (elements []byte, names []string, err error) {
+	if err = raw.validateFrame(); err != nil {
+		return nil, nil, err
+	}
+
+	elements = raw[kInt32Size : len(raw)-1]
+	names = make([]string, 0)
+	for pos := 0; pos < len(elements); {
+		et := etype(elements[pos])
+		pos++
+
+		nameSize := bytelib.IndexByte(elements[pos:], kNullTerminator)
+		if nameSize < 0 {
+			return nil, nil, fmt.Errorf("raw document has an unterminated ename")
+		}
+		names = append(names, string(elements[pos:pos+nameSize]))
+		pos += nameSize + 1
+
+		size, err := evalueSize(elements[pos:], et)
+		if err != nil {
+			return nil, nil, fmt.Errorf("raw document element {%v}: %w", names[len(names)-1], err)
+		}
+		if err = (rawValue{et: et, data: elements[pos : pos+size]}).validate(); err != nil {
+			return nil, nil, fmt.Errorf("raw document element {%v}: %w", names[len(names)-1], err)
+		}
+		pos += size
+	}
+
+	return elements, names, nil
+}
+
+// rawFromElements wraps a sequence of marshalled elements (etype, ename, evalue) into a document.
+func rawFromElements(elements []byte) Raw {
+	raw := make(Raw, kInt32Size, kMinDocumentSize+len(elements))
+	binlib.LittleEndian.PutUint32(raw, uint32(kMinDocumentSize+len(elements)))
+	raw = append(raw, elements...)
+	return append(raw, byte(kEtypeDone))
+}
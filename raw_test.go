@@ -0,0 +1,88 @@
+package ezbson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawValidate(t *testing.T) {
+	assert.Nil(t, Raw{0x05, 0x00, 0x00, 0x00, 0x00}.Validate())
+	assert.NotNil(t, Raw{0x05, 0x00, 0x00, 0x00}.Validate())                   // too short
+	assert.NotNil(t, Raw{0x06, 0x00, 0x00, 0x00, 0x00}.Validate())             // wrong size
+	assert.NotNil(t, Raw{0x05, 0x00, 0x00, 0x00, 0x01}.Validate())             // not terminated
+	assert.NotNil(t, Raw{0x07, 0x00, 0x00, 0x00, 0x99, 0x00, 0x00}.Validate()) // invalid element
+}
+
+func TestRawMarshalUnmarshal(t *testing.T) {
+	inner, err := Marshal(map[string]any{"x": int32(5)})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	kMarshalled := []byte{
+		0x14, 0x00, 0x00, 0x00, // total document size
+		0x03, // etype-document
+		'R', 0x00,
+		0x0c, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'x', 0x00,
+		0x05, 0x00, 0x00, 0x00,
+		0x00,
+		0x00,
+	}
+
+	marshalled, err := Marshal(map[string]any{"R": Raw(inner)})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asStruct := struct {
+		R Raw
+	}{}
+	if err := Unmarshal(kMarshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, Raw(inner), asStruct.R)
+
+	_, err = Marshal(map[string]any{"R": Raw{0x01, 0x02}})
+	assert.NotNil(t, err)
+}
+
+func TestRawFromElements(t *testing.T) {
+	assert.Equal(t, Raw{0x05, 0x00, 0x00, 0x00, 0x00}, rawFromElements(nil))
+	assert.Equal(t, Raw{0x08, 0x00, 0x00, 0x00, 0x0a, 'a', 0x00, 0x00}, rawFromElements([]byte{0x0a, 'a', 0x00}))
+}
+
+func TestRawElements(t *testing.T) {
+	raw := Raw{
+		0x13, 0x00, 0x00, 0x00, // total document size
+		0x08, // etype-boolean
+		'b', 0x00,
+		0x01,
+		0x10, // etype-int32
+		'a', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x0a, // etype-null
+		'n', 0x00,
+		0x00,
+	}
+
+	elements, names, err := raw.elements()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []byte(raw[4:len(raw)-1]), elements)
+	assert.Equal(t, []string{"b", "a", "n"}, names)
+
+	truncated := Raw{
+		0x0a, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'a', 0x00,
+		0x02, 0x00,
+		0x00,
+	}
+	_, _, err = truncated.elements()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,99 @@
+package ezbson
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Regex is the BSON regular expression type (etype 11).
+//
+// Options must be sorted alphabetically (as required by the BSON spec).
+// The options defined by the spec are:
+//   - i: case insensitive matching.
+//   - m: multiline matching.
+//   - s: dotall mode ('.' matches everything).
+//   - x: verbose mode.
+//   - l: make \w, \W, etc. locale dependent.
+//   - u: make \w, \W, etc. match unicode.
+type Regex struct {
+	Pattern string
+	Options string
+}
+
+// String implements fmt.Stringer, using the javascript-like /pattern/options notation.
+func (r Regex) String() string {
+	return fmt.Sprintf("/%v/%v", r.Pattern, r.Options)
+}
+
+// Validate checks that r can be stored as a BSON regex:
+// neither the pattern nor the options may contain null bytes, and the options must be sorted (without duplicates).
+func (r Regex) Validate() error {
+	if strings.IndexByte(r.Pattern, 0) >= 0 {
+		return fmt.Errorf("null bytes not allowed in regex pattern (%v)", r.Pattern)
+	}
+	if strings.IndexByte(r.Options, 0) >= 0 {
+		return fmt.Errorf("null bytes not allowed in regex options (%v)", r.Options)
+	}
+
+	for i := 1; i < len(r.Options); i++ {
+		if r.Options[i-1] >= r.Options[i] {
+			return fmt.Errorf("regex options must be sorted and unique (%v)", r.Options)
+		}
+	}
+
+	return nil
+}
+
+// Regexp compiles r into a *regexp.Regexp.
+//
+// The options i, m and s are mapped to the corresponding golang flags, and u is ignored (golang regexps are always unicode-aware).
+// An error is returned for options that have no golang equivalent (x and l), and for patterns that golang cannot compile.
+func (r Regex) Regexp() (*regexp.Regexp, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	flags := ""
+	for _, option := range r.Options {
+		switch option {
+		case 'i', 'm', 's':
+			flags += string(option)
+		case 'u':
+		default:
+			return nil, fmt.Errorf("regex option %q is not supported by golang", option)
+		}
+	}
+
+	pattern := r.Pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
+// regexpFlagsPrefix matches a leading flag group (such as "(?is)"), which is converted to BSON regex options.
+var regexpFlagsPrefix = regexp.MustCompile(`^\(\?([ims]+)\)`)
+
+// RegexFromRegexp converts a *regexp.Regexp into a Regex.
+//
+// A leading flag group consisting of the flags i, m and s (e.g. "(?is)abc") is converted to options,
+// anything else is kept in the pattern as-is.
+func RegexFromRegexp(re *regexp.Regexp) Regex {
+	pattern := re.String()
+
+	match := regexpFlagsPrefix.FindStringSubmatch(pattern)
+	if match == nil {
+		return Regex{Pattern: pattern}
+	}
+
+	options := ""
+	for _, option := range "ims" { // Sorted, without duplicates
+		if strings.ContainsRune(match[1], option) {
+			options += string(option)
+		}
+	}
+
+	return Regex{Pattern: pattern[len(match[0]):], Options: options}
+}
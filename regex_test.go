@@ -0,0 +1,88 @@
+package ezbson
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexValidate(t *testing.T) {
+	assert.Nil(t, Regex{Pattern: "^abc$", Options: ""}.Validate())
+	assert.Nil(t, Regex{Pattern: "^abc$", Options: "imsx"}.Validate())
+	assert.NotNil(t, Regex{Pattern: "^abc$", Options: "mi"}.Validate())
+	assert.NotNil(t, Regex{Pattern: "^abc$", Options: "ii"}.Validate())
+	assert.NotNil(t, Regex{Pattern: "a\x00b", Options: ""}.Validate())
+}
+
+func TestRegexToRegexp(t *testing.T) {
+	re, err := Regex{Pattern: "^hello.world$", Options: "is"}.Regexp()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, re.MatchString("HELLO\nWORLD"))
+
+	_, err = Regex{Pattern: "abc", Options: "x"}.Regexp()
+	assert.NotNil(t, err)
+
+	_, err = Regex{Pattern: "(", Options: ""}.Regexp()
+	assert.NotNil(t, err)
+}
+
+func TestRegexFromRegexp(t *testing.T) {
+	assert.Equal(t, Regex{Pattern: "abc", Options: "ims"}, RegexFromRegexp(regexp.MustCompile("(?smi)abc")))
+	assert.Equal(t, Regex{Pattern: "a(?i)bc", Options: ""}, RegexFromRegexp(regexp.MustCompile("a(?i)bc")))
+	assert.Equal(t, Regex{Pattern: "(?U)abc", Options: ""}, RegexFromRegexp(regexp.MustCompile("(?U)abc")))
+}
+
+func TestRegexMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x0f, 0x00, 0x00, 0x00, // total document size
+		0x0b, // etype-regex
+		'R', 0x00,
+		'^', 'a', 'b', 0x00, // pattern
+		'i', 'm', 0x00, // options
+		0x00,
+	}
+
+	regex := Regex{Pattern: "^ab", Options: "im"}
+
+	marshalled, err := Marshal(map[string]any{"R": regex})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	marshalled, err = Marshal(map[string]any{"R": regexp.MustCompile("(?mi)^ab")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"R": regex}, asMap)
+
+	asStruct := struct {
+		R *regexp.Regexp
+	}{}
+	if err := Unmarshal(kMarshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, asStruct.R.MatchString("x\nAB"))
+
+	_, err = Marshal(map[string]any{"R": Regex{Pattern: "^ab", Options: "mi"}})
+	assert.NotNil(t, err)
+
+	unsorted := []byte{
+		0x0f, 0x00, 0x00, 0x00, // total document size
+		0x0b, // etype-regex
+		'R', 0x00,
+		'^', 'a', 'b', 0x00, // pattern
+		'm', 'i', 0x00, // options
+		0x00,
+	}
+	assert.NotNil(t, Unmarshal(unsorted, &asMap))
+}
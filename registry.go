@@ -0,0 +1,204 @@
+package ezbson
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EncodeFunc marshals val into an evalue, and returns its etype (e.g. 0x02 for a string) and the marshalled evalue.
+// val is of the registered type (or implements the registered interface).
+type EncodeFunc func(val any) (etype byte, data []byte, err error)
+
+// DecodeFunc unmarshals an evalue of type etype into the value that ptr points to.
+// ptr is a pointer to the registered type (or to a type that implements the registered interface).
+// data is only valid during the call, so it must be copied if it is retained.
+//
+// A DecodeFunc is not called for a BSON null (or undefined), which sets the value to its zero-value as usual.
+type DecodeFunc func(etype byte, data []byte, ptr any) error
+
+// Registry holds custom encode and decode functions per type, for types that cannot implement ValueMarshaler
+// (e.g. types from other modules). See Encoder.Registry and Decoder.Registry.
+//
+// A Registry is built by a RegistryBuilder, and is immutable (so it is safe for concurrent use).
+// The functions of a Registry take precedence over all other ways of marshalling and unmarshalling a type.
+type Registry struct {
+	typeEncoders  map[reflect.Type]EncodeFunc
+	typeDecoders  map[reflect.Type]DecodeFunc
+	ifaceEncoders []ifaceEncoder // In registration order
+	ifaceDecoders []ifaceDecoder // In registration order
+}
+
+type ifaceEncoder struct {
+	iface  reflect.Type
+	encode EncodeFunc
+}
+
+type ifaceDecoder struct {
+	iface  reflect.Type
+	decode DecodeFunc
+}
+
+// RegistryBuilder builds a Registry. The zero value is ready to use.
+//
+// Errors (such as registering a non-interface type as an interface) are returned by Build.
+// A RegistryBuilder is not safe for concurrent use.
+type RegistryBuilder struct {
+	registry Registry
+	errs     []error
+}
+
+// NewRegistryBuilder returns an empty RegistryBuilder.
+func NewRegistryBuilder() *RegistryBuilder {
+	return &RegistryBuilder{}
+}
+
+// RegisterTypeEncoder registers encode for values of type rtype (a later registration of the same type replaces it).
+func (rb *RegistryBuilder) RegisterTypeEncoder(rtype reflect.Type, encode EncodeFunc) *RegistryBuilder {
+	if rtype == nil || encode == nil {
+		rb.errs = append(rb.errs, fmt.Errorf("RegisterTypeEncoder: rtype and encode must not be nil"))
+		return rb
+	}
+
+	if rb.registry.typeEncoders == nil {
+		rb.registry.typeEncoders = make(map[reflect.Type]EncodeFunc)
+	}
+	rb.registry.typeEncoders[rtype] = encode
+	return rb
+}
+
+// RegisterTypeDecoder registers decode for values of type rtype (a later registration of the same type replaces it).
+//
+// If rtype is a pointer type (e.g. *time.Location), decode is called with a pointer to the pointer,
+// instead of the pointer being allocated as usual. This is useful for types that must not be copied.
+func (rb *RegistryBuilder) RegisterTypeDecoder(rtype reflect.Type, decode DecodeFunc) *RegistryBuilder {
+	if rtype == nil || decode == nil {
+		rb.errs = append(rb.errs, fmt.Errorf("RegisterTypeDecoder: rtype and decode must not be nil"))
+		return rb
+	}
+
+	if rb.registry.typeDecoders == nil {
+		rb.registry.typeDecoders = make(map[reflect.Type]DecodeFunc)
+	}
+	rb.registry.typeDecoders[rtype] = decode
+	return rb
+}
+
+// RegisterInterfaceEncoder registers encode for values whose type implements the interface iface
+// (e.g. reflect.TypeOf((*fmt.Stringer)(nil)).Elem()). Types registered with RegisterTypeEncoder take precedence,
+// and interfaces are matched in registration order.
+func (rb *RegistryBuilder) RegisterInterfaceEncoder(iface reflect.Type, encode EncodeFunc) *RegistryBuilder {
+	if iface == nil || iface.Kind() != reflect.Interface || encode == nil {
+		rb.errs = append(rb.errs, fmt.Errorf("RegisterInterfaceEncoder: %v is not an interface (or encode is nil)", iface))
+		return rb
+	}
+
+	rb.registry.ifaceEncoders = append(rb.registry.ifaceEncoders, ifaceEncoder{iface: iface, encode: encode})
+	return rb
+}
+
+// RegisterInterfaceDecoder registers decode for values whose type (or a pointer to it) implements the interface iface.
+// Types registered with RegisterTypeDecoder take precedence, and interfaces are matched in registration order.
+func (rb *RegistryBuilder) RegisterInterfaceDecoder(iface reflect.Type, decode DecodeFunc) *RegistryBuilder {
+	if iface == nil || iface.Kind() != reflect.Interface || decode == nil {
+		rb.errs = append(rb.errs, fmt.Errorf("RegisterInterfaceDecoder: %v is not an interface (or decode is nil)", iface))
+		return rb
+	}
+
+	rb.registry.ifaceDecoders = append(rb.registry.ifaceDecoders, ifaceDecoder{iface: iface, decode: decode})
+	return rb
+}
+
+// Build returns a Registry with everything registered so far. The builder may be reused afterwards,
+// without affecting the returned Registry.
+func (rb *RegistryBuilder) Build() (*Registry, error) {
+	if len(rb.errs) > 0 {
+		return nil, fmt.Errorf("ezbson.RegistryBuilder: %w", rb.errs[0])
+	}
+
+	registry := &Registry{
+		typeEncoders:  make(map[reflect.Type]EncodeFunc, len(rb.registry.typeEncoders)),
+		typeDecoders:  make(map[reflect.Type]DecodeFunc, len(rb.registry.typeDecoders)),
+		ifaceEncoders: append([]ifaceEncoder{}, rb.registry.ifaceEncoders...),
+		ifaceDecoders: append([]ifaceDecoder{}, rb.registry.ifaceDecoders...),
+	}
+	for rtype, encode := range rb.registry.typeEncoders {
+		registry.typeEncoders[rtype] = encode
+	}
+	for rtype, decode := range rb.registry.typeDecoders {
+		registry.typeDecoders[rtype] = decode
+	}
+
+	return registry, nil
+}
+
+// lookupEncoder returns the EncodeFunc for values of type rtype, or nil if there is none (or if registry is nil).
+func (registry *Registry) lookupEncoder(rtype reflect.Type) EncodeFunc {
+	if registry == nil {
+		return nil
+	}
+
+	if encode, ok := registry.typeEncoders[rtype]; ok {
+		return encode
+	}
+
+	for _, entry := range registry.ifaceEncoders {
+		if rtype.Implements(entry.iface) {
+			return entry.encode
+		}
+	}
+
+	return nil
+}
+
+// hasTypeDecoder reports whether a DecodeFunc is registered for exactly rtype (interfaces are not matched).
+func (registry *Registry) hasTypeDecoder(rtype reflect.Type) bool {
+	if registry == nil {
+		return false
+	}
+
+	_, ok := registry.typeDecoders[rtype]
+	return ok
+}
+
+// lookupDecoder returns the DecodeFunc for values of type rtype, or nil if there is none (or if registry is nil).
+func (registry *Registry) lookupDecoder(rtype reflect.Type) DecodeFunc {
+	if registry == nil {
+		return nil
+	}
+
+	if decode, ok := registry.typeDecoders[rtype]; ok {
+		return decode
+	}
+
+	for _, entry := range registry.ifaceDecoders {
+		if rtype.Implements(entry.iface) || reflect.PointerTo(rtype).Implements(entry.iface) {
+			return entry.decode
+		}
+	}
+
+	return nil
+}
+
+// resolveEncoder calls the EncodeFunc registered for val (or for its pointee, or for a pointer to it),
+// and returns its result as a rawValue. If there is none, val is returned as-is.
+func (registry *Registry) resolveEncoder(val any) (any, error) {
+	if registry == nil {
+		return val, nil
+	}
+
+	target := findMarshaler(val, func(rtype reflect.Type) bool { return registry.lookupEncoder(rtype) != nil })
+	if target == nil {
+		return val, nil
+	}
+
+	et, data, err := registry.lookupEncoder(reflect.TypeOf(target))(target)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %T: %w", target, err)
+	}
+
+	result := rawValue{et: etype(et), data: data}
+	if err = result.validate(); err != nil {
+		return nil, fmt.Errorf("encoding %T: %w", target, err)
+	}
+	return result, nil
+}
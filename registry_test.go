@@ -0,0 +1,206 @@
+package ezbson
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// celsius stands for a type from another module (which can't implement ValueMarshaler)
+type celsius float64
+
+func encodeCelsius(val any) (byte, []byte, error) {
+	data, err := appendString(nil, fmt.Sprintf("%vC", float64(val.(celsius))))
+	return byte(kEtypeString), data, err
+}
+
+func decodeCelsius(et byte, data []byte, ptr any) error {
+	if etype(et) != kEtypeString {
+		return fmt.Errorf("unexpected etype %v", et)
+	}
+
+	var s string
+	if _, err := readEstring(bytes.NewBuffer(data), &s); err != nil {
+		return err
+	}
+
+	var degrees float64
+	if _, err := fmt.Sscanf(strings.TrimSuffix(s, "C"), "%g", &degrees); err != nil {
+		return err
+	}
+	*ptr.(*celsius) = celsius(degrees)
+	return nil
+}
+
+// shouter implements fmt.Stringer, and is registered by interface
+type shouter struct {
+	Word string
+}
+
+func (s shouter) String() string {
+	return strings.ToUpper(s.Word)
+}
+
+func (s *shouter) Set(text string) {
+	s.Word = strings.ToLower(text)
+}
+
+type setter interface {
+	Set(text string)
+}
+
+var (
+	stringerRtype = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	setterRtype   = reflect.TypeOf((*setter)(nil)).Elem()
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	registry, err := NewRegistryBuilder().
+		RegisterTypeEncoder(reflect.TypeOf(celsius(0)), encodeCelsius).
+		RegisterTypeDecoder(reflect.TypeOf(celsius(0)), decodeCelsius).
+		RegisterInterfaceEncoder(stringerRtype, func(val any) (byte, []byte, error) {
+			data, err := appendString(nil, val.(fmt.Stringer).String())
+			return byte(kEtypeString), data, err
+		}).
+		RegisterInterfaceDecoder(setterRtype, func(et byte, data []byte, ptr any) error {
+			var s string
+			if _, err := readEstring(bytes.NewBuffer(data), &s); err != nil {
+				return err
+			}
+			ptr.(setter).Set(s)
+			return nil
+		}).
+		Build()
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	return registry
+}
+
+func TestRegistryRoundTrip(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	type S struct {
+		Temp    celsius
+		TempPtr *celsius
+		Temps   map[string]celsius
+		Word    shouter
+	}
+
+	temp := celsius(-3)
+	expected := S{Temp: 21.5, TempPtr: &temp, Temps: map[string]celsius{"max": 30}, Word: shouter{Word: "hello"}}
+
+	marshalled, err := (&Encoder{Registry: registry}).Marshal(expected)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(marshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{
+		"Temp":    "21.5C",
+		"TempPtr": "-3C",
+		"Temps":   map[string]any{"max": "30C"},
+		"Word":    "HELLO",
+	}, asMap)
+
+	actual := S{}
+	if err := (&Decoder{Registry: registry}).Unmarshal(marshalled, &actual); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+
+	// Without the registry, the string can't be converted into a celsius
+	assert.NotNil(t, Unmarshal(marshalled, &S{}))
+}
+
+func TestRegistryPrecedence(t *testing.T) {
+	registry, err := NewRegistryBuilder().
+		RegisterTypeEncoder(reflect.TypeOf(money{}), func(val any) (byte, []byte, error) {
+			return byte(kEtypeInt64), []byte{1, 0, 0, 0, 0, 0, 0, 0}, nil
+		}).
+		Build()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	marshalled, err := (&Encoder{Registry: registry}).Marshal(map[string]any{"m": money{Cents: 5}})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(marshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"m": int64(1)}, asMap) // The registry takes precedence over ValueMarshaler
+}
+
+func TestRegistryBuilder(t *testing.T) {
+	_, err := NewRegistryBuilder().RegisterInterfaceEncoder(reflect.TypeOf(0), encodeCelsius).Build()
+	assert.NotNil(t, err)
+
+	_, err = NewRegistryBuilder().RegisterTypeDecoder(reflect.TypeOf(0), nil).Build()
+	assert.NotNil(t, err)
+
+	// The built Registry is not affected by later registrations
+	builder := NewRegistryBuilder()
+	registry, err := builder.Build()
+	if !assert.Nil(t, err) {
+		return
+	}
+	builder.RegisterTypeEncoder(reflect.TypeOf(celsius(0)), encodeCelsius)
+	assert.Nil(t, registry.lookupEncoder(reflect.TypeOf(celsius(0))))
+
+	// A nil Registry has no functions
+	var nilRegistry *Registry
+	assert.Nil(t, nilRegistry.lookupDecoder(reflect.TypeOf(celsius(0))))
+}
+
+func TestRegistryEncoderErrors(t *testing.T) {
+	registry, err := NewRegistryBuilder().
+		RegisterTypeEncoder(reflect.TypeOf(celsius(0)), func(val any) (byte, []byte, error) {
+			return byte(kEtypeInt32), []byte{1}, nil // too short
+		}).
+		Build()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = (&Encoder{Registry: registry}).Marshal(map[string]any{"t": celsius(1)})
+	assert.NotNil(t, err)
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	registry := newTestRegistry(t)
+	enc := Encoder{Registry: registry}
+	dec := Decoder{Registry: registry}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			expected := map[string]celsius{"t": celsius(i)}
+			marshalled, err := enc.Marshal(expected)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			actual := make(map[string]celsius)
+			if assert.Nil(t, dec.Unmarshal(marshalled, &actual)) {
+				assert.Equal(t, expected, actual)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
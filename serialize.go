@@ -7,10 +7,12 @@ package ezbson
 
 import (
 	"bytes"
+	"encoding"
 	binlib "encoding/binary"
 	"fmt"
 	"math"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"time"
@@ -28,16 +30,16 @@ const (
 	kEtypeDocument       etype = 0x03
 	kEtypeArray          etype = 0x04
 	kEtypeBinary         etype = 0x05
-	kEtypeDeprecated6    etype = 0x06
+	kEtypeUndefined      etype = 0x06
 	kEtypeObjectId       etype = 0x07
 	kEtypeBoolean        etype = 0x08
 	kEtypeUtcDatetime    etype = 0x09
 	kEtypeNull           etype = 0x0a
 	kEtypeRegex          etype = 0x0b
-	kEtypeDeprecated12   etype = 0x0c
+	kEtypeDBPointer      etype = 0x0c
 	kEtypeJavascriptCode etype = 0x0d
-	kEtypeDeprecated14   etype = 0x0e
-	kEtypeDeprecated15   etype = 0x0f
+	kEtypeSymbol         etype = 0x0e
+	kEtypeCodeWithScope  etype = 0x0f
 	kEtypeInt32          etype = 0x10
 	kEtypeMongoTimestamp etype = 0x11
 	kEtypeInt64          etype = 0x12
@@ -47,7 +49,6 @@ const (
 )
 
 const (
-	kBinarySubtype  byte = 0
 	kNullTerminator byte = 0
 )
 
@@ -60,94 +61,183 @@ func validate64bit() error {
 	return nil
 }
 
-func getEtype(val any) (etype, error) {
+// isNull reports whether val should be serialized as BSON null:
+// nil interfaces, nil pointers, nil maps and nil slices are.
+func isNull(val any) bool {
+	if val == nil {
+		return true
+	}
+
+	rvalue := reflect.ValueOf(val)
+	switch rvalue.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		return rvalue.IsNil()
+	default:
+		return false
+	}
+}
+
+func (enc *Encoder) getEtype(val any) (etype, error) {
+	if isNull(val) {
+		return kEtypeNull, nil
+	}
+
+	if _, ok := val.(*regexp.Regexp); ok { // Must be checked before dereferencing
+		return kEtypeRegex, nil
+	}
+
 	rtype := reflect.TypeOf(val)
 	rkind := rtype.Kind()
 
 	if rkind == reflect.Pointer {
-		return getEtype(reflect.ValueOf(val).Elem().Interface())
+		return enc.getEtype(reflect.ValueOf(val).Elem().Interface())
 	}
 
+	if raw, ok := val.(rawValue); ok { // As returned by a ValueMarshaler
+		return raw.et, nil
+	}
+
+	// ezbson's own types (and time.Time) are matched exactly, everything else is matched by its kind.
 	switch val.(type) {
-	case float64:
-		return kEtypeDouble, nil
-	case string:
-		return kEtypeString, nil
-	case []byte:
+	case Binary:
 		return kEtypeBinary, nil
-	case bool:
-		return kEtypeBoolean, nil
+	case UUID:
+		return kEtypeBinary, nil
+	case ObjectID:
+		return kEtypeObjectId, nil
 	case time.Time:
 		return kEtypeUtcDatetime, nil
-	case int32:
-		return kEtypeInt32, nil
-	case int:
-		return kEtypeInt64, nil
-	case int64:
-		return kEtypeInt64, nil
+	case Regex:
+		return kEtypeRegex, nil
+	case JavaScript:
+		return kEtypeJavascriptCode, nil
+	case CodeWithScope:
+		return kEtypeCodeWithScope, nil
+	case Timestamp:
+		return kEtypeMongoTimestamp, nil
+	case Decimal128:
+		return kEtypeDecimal128, nil
+	case Raw:
+		return kEtypeDocument, nil
+	case MinKey:
+		return kEtypeMinKey, nil
+	case MaxKey:
+		return kEtypeMaxKey, nil
+	case Undefined, DBPointer, Symbol:
+		if !enc.AllowDeprecatedTypes {
+			return 0, fmt.Errorf("deprecated type %T is not allowed (see Encoder.AllowDeprecatedTypes)", val)
+		}
+		switch val.(type) {
+		case Undefined:
+			return kEtypeUndefined, nil
+		case DBPointer:
+			return kEtypeDBPointer, nil
+		default:
+			return kEtypeSymbol, nil
+		}
 	default:
 		break
 	}
 
 	switch rkind {
+	case reflect.Float32, reflect.Float64:
+		return kEtypeDouble, nil
+	case reflect.String:
+		return kEtypeString, nil
+	case reflect.Bool:
+		return kEtypeBoolean, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return kEtypeInt32, nil
+	case reflect.Int, reflect.Int64, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return kEtypeInt64, nil
 	case reflect.Map:
 		return kEtypeDocument, nil
 	case reflect.Struct:
 		return kEtypeDocument, nil
 	case reflect.Slice:
+		if isByteSliceRtype(rtype) {
+			return kEtypeBinary, nil
+		}
+		return kEtypeArray, nil
+	case reflect.Array:
+		if isByteArrayRtype(rtype) {
+			return kEtypeBinary, nil
+		}
 		return kEtypeArray, nil
 	default:
 		return 0, fmt.Errorf("unsupported type %T", val)
 	}
 }
 
-func appendAny(buffer []byte, val_any any) ([]byte, error) {
+// isByteSliceRtype reports whether rtype is a []byte, or any other slice whose elements are of kind uint8 (e.g. a named []byte).
+func isByteSliceRtype(rtype reflect.Type) bool {
+	return rtype.Kind() == reflect.Slice && rtype.Elem().Kind() == reflect.Uint8
+}
+
+// isByteArrayRtype reports whether rtype is a golang array whose elements are of kind uint8 (e.g. [32]byte).
+func isByteArrayRtype(rtype reflect.Type) bool {
+	return rtype.Kind() == reflect.Array && rtype.Elem().Kind() == reflect.Uint8
+}
+
+func (enc *Encoder) appendAny(buffer []byte, val_any any) ([]byte, error) {
 	var err error
 
+	if re, ok := val_any.(*regexp.Regexp); ok { // Must be checked before dereferencing
+		val_any = RegexFromRegexp(re)
+	}
+
 	valRtype := reflect.TypeOf(val_any)
 	valRkind := valRtype.Kind()
 
 	if valRkind == reflect.Pointer {
-		return appendAny(buffer, reflect.ValueOf(val_any).Elem().Interface()) // .Interface() copies
+		return enc.appendAny(buffer, reflect.ValueOf(val_any).Elem().Interface()) // .Interface() copies
 	}
 
 	switch val := val_any.(type) {
-	case []byte:
-		if len(val) > math.MaxInt32 {
-			return buffer, fmt.Errorf("byte slice too big (%v)", len(val))
+	case Binary:
+		buffer, err = appendBinary(buffer, val)
+	case UUID:
+		buffer, err = appendBinary(buffer, Binary{Subtype: BinarySubtypeUUID, Data: val[:]})
+	case ObjectID:
+		buffer = append(buffer, val[:]...)
+	case JavaScript:
+		buffer, err = appendString(buffer, string(val))
+	case CodeWithScope:
+		buffer, err = enc.appendCodeWithScope(buffer, val)
+	case time.Time:
+		if enc.DisallowTimePrecisionLoss && val.Nanosecond()%int(time.Millisecond) != 0 {
+			return buffer, fmt.Errorf("time %v has sub-millisecond precision, which a UTC datetime cannot hold (see Encoder.DisallowTimePrecisionLoss)", val)
 		}
-		buffer, err = appendInt32(buffer, int32(len(val)))
-		if err != nil {
+		val_int64 := val.UTC().UnixMilli()
+		buffer, err = appendInt64(buffer, val_int64)
+	case Regex:
+		if err = val.Validate(); err != nil {
 			return buffer, err
 		}
-		buffer = append(buffer, kBinarySubtype)
-		buffer = append(buffer, val...)
-	case string:
-		if len(val)+1 > math.MaxInt32 {
-			return buffer, fmt.Errorf("string too long (%v)", len(val))
+		buffer = appendCstring(buffer, val.Pattern)
+		buffer = appendCstring(buffer, val.Options)
+	case Timestamp:
+		buffer, err = appendInt64(buffer, int64(val.uint64()))
+	case Decimal128:
+		buffer, err = appendDecimal128(buffer, val)
+	case Raw:
+		if err = val.Validate(); err != nil {
+			return buffer, err
 		}
-		buffer, err = appendInt32(buffer, int32(len(val)+1))
-		if err != nil {
+		buffer = append(buffer, val...)
+	case rawValue:
+		buffer = append(buffer, val.data...) // Validated by resolveMarshaler (or resolveEncoder)
+	case MinKey, MaxKey, Undefined:
+		// min_key, max_key and undefined have no evalue
+	case Symbol:
+		buffer, err = appendString(buffer, string(val))
+	case DBPointer:
+		if buffer, err = appendString(buffer, val.Ref); err != nil {
 			return buffer, err
 		}
-		buffer = append(buffer, []byte(val)...)
-		buffer = append(buffer, kNullTerminator)
-
-	case float64:
-		buffer, err = appendFloat64(buffer, val)
-	case bool:
-		buffer, err = appendBoolean(buffer, val)
-	case time.Time:
-		val_int64 := val.UTC().UnixMilli()
-		buffer, err = appendInt64(buffer, val_int64)
-	case int32:
-		buffer, err = appendInt32(buffer, val)
-	case int:
-		buffer, err = appendInt64(buffer, int64(val))
-	case int64:
-		buffer, err = appendInt64(buffer, val)
+		buffer = append(buffer, val.ID[:]...)
 	default:
-		buffer, err = appendOther(buffer, val)
+		buffer, err = enc.appendKind(buffer, reflect.ValueOf(val))
 	}
 
 	if err != nil {
@@ -157,7 +247,49 @@ func appendAny(buffer []byte, val_any any) ([]byte, error) {
 	return buffer, nil
 }
 
-func appendMap(buffer []byte, doc map[string]any) ([]byte, error) {
+// handles the basic kinds (so named types, such as `type Status string`, are supported too).
+func (enc *Encoder) appendKind(buffer []byte, rvalue reflect.Value) ([]byte, error) {
+	switch rvalue.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return appendFloat64(buffer, rvalue.Float())
+	case reflect.String:
+		return appendString(buffer, rvalue.String())
+	case reflect.Bool:
+		return appendBoolean(buffer, rvalue.Bool())
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return appendInt32(buffer, int32(rvalue.Int()))
+	case reflect.Uint8, reflect.Uint16:
+		return appendInt32(buffer, int32(rvalue.Uint()))
+	case reflect.Int, reflect.Int64:
+		return appendInt64(buffer, rvalue.Int())
+	case reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return appendUint64(buffer, rvalue.Uint())
+	case reflect.Slice:
+		if isByteSliceRtype(rvalue.Type()) {
+			return appendBinary(buffer, Binary{Subtype: BinarySubtypeGeneric, Data: rvalue.Bytes()})
+		}
+		return enc.appendOther(buffer, rvalue.Interface())
+	case reflect.Array:
+		if isByteArrayRtype(rvalue.Type()) {
+			data := make([]byte, rvalue.Len()) // arrays aren't necessarily addressable, so Bytes() can't be used
+			for i := range data {
+				data[i] = byte(rvalue.Index(i).Uint())
+			}
+			return appendBinary(buffer, Binary{Subtype: BinarySubtypeGeneric, Data: data})
+		}
+		return enc.appendOther(buffer, rvalue.Interface())
+	default:
+		return enc.appendOther(buffer, rvalue.Interface())
+	}
+}
+
+func (enc *Encoder) appendMap(buffer []byte, doc map[string]any) ([]byte, error) {
+	return enc.appendDocument(buffer, doc, sortedKeys(doc), nil)
+}
+
+// appendDocument appends doc as a document, with its elements in the order of keys.
+// rawElements (already marshalled elements, e.g. of a Raw remainder) are appended as-is after them.
+func (enc *Encoder) appendDocument(buffer []byte, doc map[string]any, keys []string, rawElements []byte) ([]byte, error) {
 	var kSizePlaceholder int32
 
 	startPos := len(buffer)
@@ -166,13 +298,17 @@ func appendMap(buffer []byte, doc map[string]any) ([]byte, error) {
 		return buffer, err
 	}
 
-	for _, key := range sortedKeys(doc) {
+	for _, key := range keys {
 		if err = validateEname(key); err != nil {
 			return buffer, err
 		}
 
-		val := doc[key]
-		et, err := getEtype(val)
+		val, err := enc.resolveValue(doc[key])
+		if err != nil {
+			return buffer, fmt.Errorf("key %v: %w", key, err)
+		}
+
+		et, err := enc.getEtype(val)
 		if err != nil {
 			return buffer, fmt.Errorf("key %v: %w", key, err)
 		}
@@ -182,11 +318,16 @@ func appendMap(buffer []byte, doc map[string]any) ([]byte, error) {
 		buffer = append(buffer, []byte(key)...)
 		buffer = append(buffer, kNullTerminator)
 
-		buffer, err = appendAny(buffer, val)
+		if et == kEtypeNull {
+			continue // null has no evalue
+		}
+
+		buffer, err = enc.appendAny(buffer, val)
 		if err != nil {
 			return buffer, fmt.Errorf("key %v: %w", key, err)
 		}
 	}
+	buffer = append(buffer, rawElements...)
 	buffer = append(buffer, byte(kEtypeDone))
 
 	endPos := len(buffer)
@@ -206,36 +347,36 @@ func appendMap(buffer []byte, doc map[string]any) ([]byte, error) {
 }
 
 // handles maps, slices, and structs (the types that require reflection)
-func appendOther(buffer []byte, val_any any) ([]byte, error) {
+func (enc *Encoder) appendOther(buffer []byte, val_any any) ([]byte, error) {
 	valType := reflect.TypeOf(val_any)
 	valKind := valType.Kind()
 
 	var err error
 	switch valKind {
 	case reflect.Map:
-		mapKeyType := valType.Key()
-		mapKeyKind := mapKeyType.Kind()
-
-		if mapKeyKind != reflect.String {
-			return buffer, fmt.Errorf("only map[string]... is supported")
+		doc, err := convertReflectMapToMapStringAny(reflect.ValueOf(val_any))
+		if err != nil {
+			return buffer, err
 		}
-		doc := convertReflectMapToMapStringAny(reflect.ValueOf(val_any))
 
-		if buffer, err = appendMap(buffer, doc); err != nil {
+		if buffer, err = enc.appendMap(buffer, doc); err != nil {
 			return buffer, err
 		}
 
-	case reflect.Slice:
-		doc := convertReflectSliceToMapStringAny(reflect.ValueOf(val_any))
+	case reflect.Slice, reflect.Array:
+		doc, keys := convertReflectSliceToMapStringAny(reflect.ValueOf(val_any))
 
-		if buffer, err = appendMap(buffer, doc); err != nil {
+		if buffer, err = enc.appendDocument(buffer, doc, keys, nil); err != nil { // The indices must not be sorted as strings
 			return buffer, err
 		}
 
 	case reflect.Struct:
-		doc := convertReflectStructToMapStringAny(reflect.ValueOf(val_any))
+		doc, remainderElements, err := enc.convertReflectStructToMapStringAny(reflect.ValueOf(val_any))
+		if err != nil {
+			return buffer, err
+		}
 
-		if buffer, err = appendMap(buffer, doc); err != nil {
+		if buffer, err = enc.appendDocument(buffer, doc, sortedKeys(doc), remainderElements); err != nil {
 			return buffer, err
 		}
 
@@ -246,16 +387,83 @@ func appendOther(buffer []byte, val_any any) ([]byte, error) {
 	return buffer, nil
 }
 
-func convertReflectStructToMapStringAny(v reflect.Value) map[string]any {
-	result := make(map[string]any)
+// Embedded and inline structs are flattened into the result (see getStructFields).
+// The elements of a Raw remainder are returned separately as remainderElements, to be appended as-is
+// (so that they keep their order and bytes).
+func (enc *Encoder) convertReflectStructToMapStringAny(v reflect.Value) (result map[string]any, remainderElements []byte, err error) {
+	fields, err := getStructFields(v.Type(), enc.NamingStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result = make(map[string]any)
+
+	for _, field := range fields.list {
+		fieldRvalue, ok := fieldByIndex(v, field.index)
+		if !ok {
+			continue // The field is in a nil embedded struct pointer
+		}
+
+		if field.omitEmpty && isEmptyValue(fieldRvalue) {
+			continue
+		}
+
+		if field.timeFormat != kTimeFormatDatetime {
+			if result[field.name], err = formatTimeField(fieldRvalue, field.timeFormat); err != nil {
+				return nil, nil, fmt.Errorf("field %v: %w", field.path, err)
+			}
+		} else if field.minSize {
+			// minsize only applies to plain integers, and not to values that marshal themselves (or have a registered encoder)
+			resolved, err := enc.resolveValue(fieldRvalue.Interface())
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %v: %w", field.path, err)
+			}
+			if _, ok := resolved.(rawValue); ok {
+				result[field.name] = resolved
+			} else {
+				result[field.name] = minSizeValue(fieldRvalue)
+			}
+		} else {
+			result[field.name] = fieldRvalue.Interface()
+		}
+	}
+
+	if fields.remainder == nil {
+		return result, nil, nil
+	}
+
+	remainderRvalue, ok := fieldByIndex(v, fields.remainder.index)
+	if !ok || remainderRvalue.IsNil() {
+		return result, nil, nil
+	}
+
+	if raw, ok := remainderRvalue.Interface().(Raw); ok {
+		elements, names, err := raw.elements()
+		if err != nil {
+			return nil, nil, fmt.Errorf("remainder %v: %w", fields.remainder.path, err)
+		}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldName := v.Type().Field(i).Name
-		result[fieldName] = field.Interface()
+		for _, name := range names {
+			if _, ok := result[name]; ok {
+				return nil, nil, fmt.Errorf("key {%v} of remainder %v conflicts with a struct field", name, fields.remainder.path)
+			}
+		}
+		return result, elements, nil
 	}
 
-	return result
+	remainder, err := convertReflectMapToMapStringAny(remainderRvalue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, val := range remainder {
+		if _, ok := result[key]; ok {
+			return nil, nil, fmt.Errorf("key {%v} of remainder %v conflicts with a struct field", key, fields.remainder.path)
+		}
+		result[key] = val
+	}
+
+	return result, nil, nil
 }
 
 // Marhsal recursively marshals a golang map[string]... or a golang struct into BSON format.
@@ -264,39 +472,158 @@ func convertReflectStructToMapStringAny(v reflect.Value) map[string]any {
 //
 // Marshal automatically dereferences pointers (so a *int64 will still be serialized into the BSON int64 type).
 //
+// Types that implement ValueMarshaler or Marshaler (with either a value or a pointer receiver) marshal themselves,
+// at any nesting level. Otherwise, types that implement encoding.TextMarshaler are marshalled as a BSON string,
+// and types that implement encoding.BinaryMarshaler as BSON binary (ezbson's own types and time.Time are excluded).
+// The functions of Encoder.Registry take precedence over all of these (see Registry).
+//
+// Types are matched by their kind, so named types are serialized like their underlying type
+// (e.g. `type Status string` is serialized as a BSON string, and `type Hash []byte` as BSON binary).
+//
+// Like encoding/json, the fields of embedded structs (and pointers to structs) are flattened into the parent document.
+// Named fields can be flattened with the `bson:",inline"` tag, and an inline map collects the elements that don't match
+// any other field. A shallower field hides deeper fields with the same name, and fields with the same name at the same
+// depth return an error.
+//
+// Maps may have string, integer (formatted as decimal) or encoding.TextMarshaler (and TextUnmarshaler) keys,
+// like encoding/json's map keys.
+//
+// nil pointers, nil interfaces, nil maps and nil slices (including a nil []byte) are serialized as BSON null.
+// At the top-level, a nil map is serialized as an empty document.
+//
 // See the examples at the package documentation for example usage, and https://bsonspec.org for more info on the BSON format.
 //
 // Below are the supported types that Marshal can convert.
 //
-//	// +----------------+------------------+
-//	// | golang type    | bson type        |
-//	// +----------------+------------------+
-//	// | float64        | double (1)       |
-//	// | string         | string (2)       |
-//	// | map[string]... | document (3)     |
-//	// | struct         | document (3)     |
-//	// | []...          | array (4)        |
-//	// | []byte         | binary (5)       |
-//	// | bool           | boolean (8)      |
-//	// | time.Time      | utc datetime (9) |
-//	// | int32          | int32 (16)       |
-//	// | int64          | int64 (18)       |
-//	// | int            | int64 (18)       |
-//	// +----------------+------------------+
+//	// +----------------+--------------------+
+//	// | golang type    | bson type          |
+//	// +----------------+--------------------+
+//	// | float64        | double (1)         |
+//	// | float32        | double (1)         |
+//	// | string         | string (2)         |
+//	// | map[string]... | document (3)       |
+//	// | struct         | document (3)       |
+//	// | Raw            | document (3)       |
+//	// | []...          | array (4)          |
+//	// | [N]...         | array (4)          |
+//	// | []byte         | binary (5)         |
+//	// | [N]byte        | binary (5)         |
+//	// | Binary         | binary (5)         |
+//	// | UUID           | binary (5)         |
+//	// | Undefined      | undefined (6)      |
+//	// | ObjectID       | objectid (7)       |
+//	// | bool           | boolean (8)        |
+//	// | time.Time      | utc datetime (9)   |
+//	// | nil            | null (10)          |
+//	// | Regex          | regex (11)         |
+//	// | *regexp.Regexp | regex (11)         |
+//	// | DBPointer      | dbpointer (12)     |
+//	// | JavaScript     | javascript (13)    |
+//	// | Symbol         | symbol (14)        |
+//	// | CodeWithScope  | code w/ scope (15) |
+//	// | int32          | int32 (16)         |
+//	// | int8, int16    | int32 (16)         |
+//	// | uint8, uint16  | int32 (16)         |
+//	// | Timestamp      | timestamp (17)     |
+//	// | int64          | int64 (18)         |
+//	// | int            | int64 (18)         |
+//	// | uint32         | int64 (18)         |
+//	// | uint, uint64   | int64 (18) (*)     |
+//	// | Decimal128     | decimal128 (19)    |
+//	// | MinKey         | min_key (-1)       |
+//	// | MaxKey         | max_key (127)      |
+//	// +----------------+--------------------+
+//
+// (*) BSON has no unsigned types, so uint and uint64 values above math.MaxInt64 return an error.
+//
+// The deprecated types (Undefined, DBPointer and Symbol) are only marshalled when Encoder.AllowDeprecatedTypes is set.
+//
+// Unexported struct fields are skipped.
+//
+// Struct fields can be customized with the `bson:"name,option1,option2..."` tag:
+//   - name is the ename of the field (by default, the golang field name is used, converted by Encoder.NamingStrategy).
+//   - `bson:"-"` skips the field.
+//   - omitempty skips the field if it is empty: false, 0, nil, an empty array, map, slice or string,
+//     or a value whose `IsZero() bool` method returns true (such as time.Time and ObjectID).
+//   - minsize marshals int64 values (int, int64, uint32, uint, uint64, or pointers to them) that fit as int32
+//     (types that marshal themselves, or that have an encoder in Encoder.Registry, are not affected).
+//   - unixnano (on a time.Time or *time.Time field) marshals the time as int64 nanoseconds since the epoch,
+//     and rfc3339nano marshals it as a time.RFC3339Nano string (which keeps the zone offset).
+//     Both keep the full nanosecond precision, while a UTC datetime is truncated to milliseconds
+//     (see Encoder.DisallowTimePrecisionLoss).
+//   - inline flattens the field into the parent document (see above).
+//   - remainder (on a map or Raw field) collects the elements that don't match any other field on Unmarshal,
+//     and its elements are marshalled into the parent document.
 //
 // Limitations:
-//   - due to the way reflect works, all structs that are being marshalled must only contain exported (uppercase) fields.
 //   - as of right now, only 64 bit architectures are supported.
 func Marshal(document any) ([]byte, error) {
+	return (&Encoder{}).Marshal(document)
+}
+
+// Encoder holds the options used for marshalling.
+//
+// The zero value is ready to use, and behaves like the package-level Marshal.
+// An Encoder must not be modified while it is being used.
+type Encoder struct {
+	// AllowDeprecatedTypes allows marshalling the deprecated BSON types (Undefined, DBPointer and Symbol).
+	// By default, marshalling them returns an error (they can always be unmarshalled).
+	AllowDeprecatedTypes bool
+
+	// NamingStrategy converts the golang names of untagged struct fields into enames (by default, they are used as-is).
+	NamingStrategy NamingStrategy
+
+	// Registry holds custom encode functions, which take precedence over everything else (may be nil).
+	Registry *Registry
+
+	// DisallowTimePrecisionLoss returns an error when marshalling a time.Time with sub-millisecond precision
+	// as a UTC datetime. By default, such times are truncated to milliseconds.
+	// See the unixnano and rfc3339nano tag options for keeping the full precision.
+	DisallowTimePrecisionLoss bool
+}
+
+// resolveValue applies enc.Registry, and then the marshaler interfaces (see resolveMarshaler).
+func (enc *Encoder) resolveValue(val any) (any, error) {
+	resolved, err := enc.Registry.resolveEncoder(val)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := resolved.(rawValue); ok {
+		return resolved, nil
+	}
+
+	return resolveMarshaler(val)
+}
+
+// Marshal is like the package-level Marshal, but uses the options set on enc.
+func (enc *Encoder) Marshal(document any) ([]byte, error) {
 	if err := validate64bit(); err != nil {
 		return nil, fmt.Errorf("ezbson.Marshal: %w", err)
 	}
 
+	if document == nil {
+		return nil, fmt.Errorf("ezbson.Marshal: cannot marshal a nil document")
+	}
+
+	resolved, err := enc.resolveValue(document)
+	if err != nil {
+		return nil, fmt.Errorf("ezbson.Marshal: %w", err)
+	}
+	if raw, ok := resolved.(rawValue); ok {
+		if raw.et != kEtypeDocument {
+			return nil, fmt.Errorf("ezbson.Marshal: at the top-level, only documents are supported (got etype %v)", raw.et)
+		}
+		return raw.data, nil
+	}
+
 	documentRtype := reflect.TypeOf(document)
 	documentRkind := documentRtype.Kind()
 
 	if documentRkind == reflect.Pointer {
-		return Marshal(reflect.ValueOf(document).Elem().Interface()) // .Interface() copies
+		if reflect.ValueOf(document).IsNil() {
+			return nil, fmt.Errorf("ezbson.Marshal: cannot marshal a nil document")
+		}
+		return enc.Marshal(reflect.ValueOf(document).Elem().Interface()) // .Interface() copies
 	}
 
 	if documentRkind != reflect.Map && documentRkind != reflect.Struct {
@@ -304,7 +631,7 @@ func Marshal(document any) ([]byte, error) {
 	}
 
 	buffer := make([]byte, 0)
-	buffer, err := appendAny(buffer, document)
+	buffer, err = enc.appendAny(buffer, document)
 	if err != nil {
 		return nil, fmt.Errorf("ezbson.Marshal: %w", err)
 	}
@@ -313,24 +640,91 @@ func Marshal(document any) ([]byte, error) {
 
 // Receives a map[string]...
 // And returns a map[string]any
-func convertReflectMapToMapStringAny(m reflect.Value) map[string]any {
+func convertReflectMapToMapStringAny(m reflect.Value) (map[string]any, error) {
+	if err := validateMapKeyRtype(m.Type().Key()); err != nil {
+		return nil, err
+	}
+
 	result := make(map[string]any)
 
 	for _, k := range m.MapKeys() {
-		result[k.String()] = m.MapIndex(k).Interface()
+		key, err := formatMapKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("duplicate key {%v} (map key %v)", key, k)
+		}
+		result[key] = m.MapIndex(k).Interface()
+	}
+
+	return result, nil
+}
+
+var (
+	textMarshalerRtype   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerRtype = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// validateMapKeyRtype checks that maps with keys of type rtype can be marshalled and unmarshalled.
+// Like encoding/json, string kinds, integer kinds, and encoding.TextMarshaler (and TextUnmarshaler) keys are supported.
+func validateMapKeyRtype(rtype reflect.Type) error {
+	switch rtype.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
 	}
 
-	return result
+	if rtype.Implements(textMarshalerRtype) || reflect.PointerTo(rtype).Implements(textUnmarshalerRtype) {
+		return nil
+	}
+
+	return fmt.Errorf("unsupported map key type %v (only string kinds, integer kinds and encoding.TextMarshaler are supported)", rtype)
 }
 
-// e.g. [100, "hello", 300] -> {"0": 100, "1": "hello", "2": 300}
-func convertReflectSliceToMapStringAny(s reflect.Value) map[string]any {
+// formatMapKey converts a map key into an ename. string kinds are used as-is, TextMarshalers are marshalled,
+// and integer kinds are formatted as decimal.
+func formatMapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+
+	if k.Kind() == reflect.Pointer && k.IsNil() { // MarshalText may not handle a nil receiver (e.g. a value receiver panics)
+		return "", fmt.Errorf("cannot marshal a nil map key of type %v", k.Type())
+	}
+
+	if marshaler, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("cannot marshal map key %v: %w", k, err)
+		}
+		return string(text), nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("unsupported map key type %v", k.Type())
+	}
+}
+
+// e.g. [100, "hello", 300] -> {"0": 100, "1": "hello", "2": 300} (s may be a slice or an array).
+// The keys are returned in index order.
+func convertReflectSliceToMapStringAny(s reflect.Value) (map[string]any, []string) {
 	m := make(map[string]any)
+	keys := make([]string, 0, s.Len())
 	for i := 0; i < s.Len(); i++ {
-		m[strconv.Itoa(i)] = s.Index(i).Interface()
+		key := strconv.Itoa(i)
+		m[key] = s.Index(i).Interface()
+		keys = append(keys, key)
 	}
 
-	return m
+	return m, keys
 }
 
 func validateEname(ename string) error {
@@ -342,6 +736,90 @@ func validateEname(ename string) error {
 	return nil
 }
 
+// binary is int32 (size of the data), subtype, data.
+// For the old binary subtype (2), the data is itself int32 (size of the payload), payload.
+func appendBinary(buffer []byte, val Binary) ([]byte, error) {
+	if val.Subtype == BinarySubtypeUUID && len(val.Data) != len(UUID{}) { // Like readEbinary
+		return buffer, fmt.Errorf("UUID binary subtype must be %v bytes (got %v)", len(UUID{}), len(val.Data))
+	}
+
+	size := len(val.Data)
+	if val.Subtype == BinarySubtypeBinaryOld {
+		size += kInt32Size
+	}
+
+	if size > math.MaxInt32 {
+		return buffer, fmt.Errorf("byte slice too big (%v)", len(val.Data))
+	}
+
+	buffer, err := appendInt32(buffer, int32(size))
+	if err != nil {
+		return buffer, err
+	}
+	buffer = append(buffer, val.Subtype)
+
+	if val.Subtype == BinarySubtypeBinaryOld {
+		if buffer, err = appendInt32(buffer, int32(len(val.Data))); err != nil {
+			return buffer, err
+		}
+	}
+
+	buffer = append(buffer, val.Data...)
+	return buffer, nil
+}
+
+func appendString(buffer []byte, val string) ([]byte, error) {
+	if len(val)+1 > math.MaxInt32 {
+		return buffer, fmt.Errorf("string too long (%v)", len(val))
+	}
+	buffer, err := appendInt32(buffer, int32(len(val)+1))
+	if err != nil {
+		return buffer, err
+	}
+	buffer = append(buffer, []byte(val)...)
+	buffer = append(buffer, kNullTerminator)
+
+	return buffer, nil
+}
+
+// code_w_s is int32 (total size, including itself), string (the code), document (the scope).
+func (enc *Encoder) appendCodeWithScope(buffer []byte, val CodeWithScope) ([]byte, error) {
+	var kSizePlaceholder int32
+
+	startPos := len(buffer)
+	buffer, err := appendInt32(buffer, kSizePlaceholder)
+	if err != nil {
+		return buffer, err
+	}
+
+	if buffer, err = appendString(buffer, string(val.Code)); err != nil {
+		return buffer, err
+	}
+
+	if buffer, err = enc.appendMap(buffer, val.Scope); err != nil {
+		return buffer, fmt.Errorf("scope: %w", err)
+	}
+
+	totalSize := len(buffer) - startPos
+	if totalSize > math.MaxInt32 {
+		return buffer, fmt.Errorf("size of code with scope too big (%v)", totalSize)
+	}
+
+	totalSize_bin, err := convertInt32ToBytes(int32(totalSize))
+	if err != nil {
+		return buffer, err
+	}
+	copy(buffer[startPos:], totalSize_bin)
+
+	return buffer, nil
+}
+
+// The caller is responsible for validating that val contains no null bytes.
+func appendCstring(buffer []byte, val string) []byte {
+	buffer = append(buffer, []byte(val)...)
+	return append(buffer, kNullTerminator)
+}
+
 func appendInt32(buffer []byte, val int32) ([]byte, error) {
 	val_bin, err := convertInt32ToBytes(val)
 	if err != nil {
@@ -362,6 +840,27 @@ func appendInt64(buffer []byte, val int64) ([]byte, error) {
 	return buffer, nil
 }
 
+// The low 64 bits come first (the whole 128 bits are little-endian).
+func appendDecimal128(buffer []byte, val Decimal128) ([]byte, error) {
+	h, l := val.GetBytes()
+
+	buffer, err := appendInt64(buffer, int64(l))
+	if err != nil {
+		return buffer, err
+	}
+
+	return appendInt64(buffer, int64(h))
+}
+
+// BSON has no unsigned types, so values above math.MaxInt64 are rejected.
+func appendUint64(buffer []byte, val uint64) ([]byte, error) {
+	if val > math.MaxInt64 {
+		return buffer, fmt.Errorf("value %v overflows int64", val)
+	}
+
+	return appendInt64(buffer, int64(val))
+}
+
 func appendFloat64(buffer []byte, val float64) ([]byte, error) {
 	val_bin, err := convertFloat64ToBytes(val)
 	if err != nil {
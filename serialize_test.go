@@ -1,6 +1,8 @@
 package ezbson
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	timelib "time"
 
@@ -1316,6 +1318,54 @@ func TestSerialize(t *testing.T) {
 				0x00, // doc-end
 			},
 		},
+		{
+			"null",
+			struct {
+				Any   any
+				Map   map[string]any
+				Ptr   *int64
+				Slice []any
+			}{},
+			[]byte{
+				0x1b, 0x00, 0x00, 0x00, // total document size
+				0x0a, // etype-null
+				'A', 'n', 'y', 0x00,
+				0x0a, // etype-null
+				'M', 'a', 'p', 0x00,
+				0x0a, // etype-null
+				'P', 't', 'r', 0x00,
+				0x0a, // etype-null
+				'S', 'l', 'i', 'c', 'e', 0x00,
+				0x00, // doc-end
+			},
+		},
+		{
+			"null in slice",
+			map[string]any{
+				"a": []any{nil, int32(1)},
+			},
+			[]byte{
+				0x17, 0x00, 0x00, 0x00, // total document size
+				0x04, // etype-array
+				'a', 0x00,
+				0x0f, 0x00, 0x00, 0x00,
+				0x0a, // etype-null
+				'0', 0x00,
+				0x10, // etype-int32
+				'1', 0x00,
+				0x01, 0x00, 0x00, 0x00,
+				0x00, // end-slice
+				0x00, // doc-end
+			},
+		},
+		{
+			"nil top-level map",
+			map[string]any(nil),
+			[]byte{
+				0x05, 0x00, 0x00, 0x00, // Size
+				0x00, // Terminator
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -1332,3 +1382,407 @@ func TestSerialize(t *testing.T) {
 		})
 	}
 }
+
+func TestSerializeNilDocument(t *testing.T) {
+	var ptr *HelloStruct
+
+	_, err := Marshal(ptr)
+	assert.NotNil(t, err)
+
+	_, err = Marshal(nil)
+	assert.NotNil(t, err)
+}
+
+func TestSerializeNumericKinds(t *testing.T) {
+	type NumericStruct struct {
+		F32 float32
+		I8  int8
+		I16 int16
+		U   uint
+		U8  uint8
+		U16 uint16
+		U32 uint32
+		U64 uint64
+	}
+
+	actual, err := Marshal(NumericStruct{
+		F32: 1.5,
+		I8:  -8,
+		I16: -16,
+		U:   1 << 40,
+		U8:  8,
+		U16: 16,
+		U32: 1 << 31,
+		U64: 1 << 62,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected, err := Marshal(map[string]any{
+		"F32": float64(1.5),
+		"I8":  int32(-8),
+		"I16": int32(-16),
+		"U":   int64(1 << 40),
+		"U8":  int32(8),
+		"U16": int32(16),
+		"U32": int64(1 << 31),
+		"U64": int64(1 << 62),
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestSerializeUint64Overflow(t *testing.T) {
+	_, err := Marshal(map[string]uint64{"U64": 1 << 63})
+	assert.NotNil(t, err)
+
+	_, err = Marshal(map[string]uint{"U": 1 << 63})
+	assert.NotNil(t, err)
+}
+
+type namedString string
+type namedInt int64
+type namedBytes []byte
+type namedMap map[namedString]namedInt
+type namedSlice []namedString
+
+func TestSerializeNamedTypes(t *testing.T) {
+	type NamedStruct struct {
+		S namedString
+		B namedBytes
+		M namedMap
+		A namedSlice
+	}
+
+	expected := []byte{
+		0x3b, 0x00, 0x00, 0x00, // total document size
+		0x04, // etype-array
+		'A', 0x00,
+		0x0e, 0x00, 0x00, 0x00,
+		0x02, // etype-string
+		'0', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		'y', 0x00,
+		0x00,
+		0x05, // etype-binary
+		'B', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x00, // subtype generic
+		0xff,
+		0x03, // etype-document
+		'M', 0x00,
+		0x10, 0x00, 0x00, 0x00,
+		0x12, // etype-int64
+		'k', 0x00,
+		0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00,
+		0x02, // etype-string
+		'S', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		'x', 0x00,
+		0x00,
+	}
+
+	actual, err := Marshal(NamedStruct{
+		S: "x",
+		B: namedBytes{0xff},
+		M: namedMap{"k": 7},
+		A: namedSlice{"y"},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestSerializeGoArrays(t *testing.T) {
+	type ArrayStruct struct {
+		C [2]int32
+		H [3]byte
+	}
+
+	expected := []byte{
+		0x26, 0x00, 0x00, 0x00, // total document size
+		0x04, // etype-array
+		'C', 0x00,
+		0x13, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'0', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'1', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x00,
+		0x05, // etype-binary
+		'H', 0x00,
+		0x03, 0x00, 0x00, 0x00,
+		0x00, // subtype generic
+		0xaa, 0xbb, 0xcc,
+		0x00,
+	}
+
+	actual, err := Marshal(ArrayStruct{C: [2]int32{1, 2}, H: [3]byte{0xaa, 0xbb, 0xcc}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+type textKey struct {
+	A, B byte
+}
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte{'a' + k.A, 'a' + k.B}, nil
+}
+
+func (k *textKey) UnmarshalText(text []byte) error {
+	if len(text) != 2 {
+		return fmt.Errorf("bad textKey %q", text)
+	}
+	k.A, k.B = text[0]-'a', text[1]-'a'
+	return nil
+}
+
+func TestSerializeMapKeys(t *testing.T) {
+	expected := []byte{
+		0x24, 0x00, 0x00, 0x00, // total document size
+		0x03, // etype-document
+		'I', 0x00,
+		0x0f, 0x00, 0x00, 0x00,
+		0x08, // etype-boolean
+		'-', '1', 0x00,
+		0x01,
+		0x08, // etype-boolean
+		'2', '0', 0x00,
+		0x00,
+		0x00,
+		0x03, // etype-document
+		'T', 0x00,
+		0x0a, 0x00, 0x00, 0x00,
+		0x08, // etype-boolean
+		'b', 'c', 0x00,
+		0x01,
+		0x00,
+		0x00,
+	}
+
+	actual, err := Marshal(map[string]any{
+		"I": map[int64]bool{-1: true, 20: false},
+		"T": map[textKey]bool{{A: 1, B: 2}: true},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+
+	_, err = Marshal(map[string]any{"F": map[float64]bool{1.5: true}})
+	assert.NotNil(t, err)
+
+	_, err = Marshal(map[string]any{"P": map[*textKey]bool{nil: true}})
+	assert.NotNil(t, err)
+}
+
+type EmbeddedInner struct {
+	X int32
+}
+
+func TestSerializeEmbeddedStructs(t *testing.T) {
+	type Named struct {
+		Y int32
+	}
+	type Outer struct {
+		*EmbeddedInner
+		N     Named          `bson:",inline"`
+		Extra map[string]any `bson:",inline"`
+	}
+
+	expected := []byte{
+		0x1a, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'X', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'Y', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'Z', 0x00,
+		0x03, 0x00, 0x00, 0x00,
+		0x00,
+	}
+
+	actual, err := Marshal(Outer{
+		EmbeddedInner: &EmbeddedInner{X: 1},
+		N:             Named{Y: 2},
+		Extra:         map[string]any{"Z": int32(3)},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+
+	// A nil embedded pointer is skipped
+	actual, err = Marshal(Outer{N: Named{Y: 2}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []byte{
+		0x0c, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'Y', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x00,
+	}, actual)
+
+	// An inline map key must not conflict with a struct field
+	_, err = Marshal(Outer{Extra: map[string]any{"Y": int32(3)}})
+	assert.NotNil(t, err)
+}
+
+func TestSerializeUnexportedFields(t *testing.T) {
+	type S struct {
+		Public int32
+		cache  map[string]any
+		mu     sync.Mutex
+	}
+
+	expected := []byte{
+		0x11, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'P', 'u', 'b', 'l', 'i', 'c', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x00,
+	}
+
+	actual, err := Marshal(&S{Public: 1, cache: map[string]any{"a": "b"}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestSerializeTags(t *testing.T) {
+	type Tagged struct {
+		CreatedAt timelib.Time `bson:"created_at,omitempty"`
+		Name      string       `bson:"name"`
+		Count     int64        `bson:"count,minsize"`
+		Big       int64        `bson:"big,minsize"`
+		Ptr       *int64       `bson:"ptr,minsize"`
+		NilPtr    *int64       `bson:"nil_ptr,minsize"`
+		Skipped   string       `bson:"-"`
+		Empty     []string     `bson:",omitempty"`
+	}
+
+	expected := []byte{
+		0x3b, 0x00, 0x00, 0x00, // total document size
+		0x12, // etype-int64
+		'b', 'i', 'g', 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'c', 'o', 'u', 'n', 't', 0x00,
+		0x07, 0x00, 0x00, 0x00,
+		0x02, // etype-string
+		'n', 'a', 'm', 'e', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		'x', 0x00,
+		0x0a, // etype-null
+		'n', 'i', 'l', '_', 'p', 't', 'r', 0x00,
+		0x10, // etype-int32
+		'p', 't', 'r', 0x00,
+		0x03, 0x00, 0x00, 0x00,
+		0x00,
+	}
+
+	three := int64(3)
+	actual, err := Marshal(Tagged{Name: "x", Count: 7, Big: 1 << 32, Ptr: &three, Skipped: "y"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestSerializeNamingStrategy(t *testing.T) {
+	type S struct {
+		UserID int32
+		Tagged int32 `bson:"TAG"`
+	}
+
+	expected := []byte{
+		0x1b, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'T', 'A', 'G', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x10, // etype-int32
+		'u', 's', 'e', 'r', '_', 'i', 'd', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x00,
+	}
+
+	actual, err := (&Encoder{NamingStrategy: NamingSnakeCase}).Marshal(S{UserID: 1, Tagged: 2})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestSerializeRawRemainder(t *testing.T) {
+	type WithRaw struct {
+		Known int32
+		Rest  Raw `bson:",remainder"`
+	}
+
+	rest := Raw{
+		0x15, 0x00, 0x00, 0x00, // total document size
+		0x0e, // etype-symbol (deprecated)
+		'z', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		's', 0x00,
+		0x10, // etype-int32
+		'a', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x00,
+	}
+
+	expected := []byte{
+		0x20, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'K', 'n', 'o', 'w', 'n', 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x0e, // etype-symbol (the remainder's elements are appended as-is, in their original order)
+		'z', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		's', 0x00,
+		0x10, // etype-int32
+		'a', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x00,
+	}
+
+	actual, err := Marshal(WithRaw{Known: 1, Rest: rest})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected, actual)
+
+	unmarshalled := WithRaw{}
+	if assert.Nil(t, Unmarshal(actual, &unmarshalled)) {
+		assert.Equal(t, WithRaw{Known: 1, Rest: rest}, unmarshalled)
+	}
+
+	conflicting := Raw{
+		0x10, 0x00, 0x00, 0x00, // total document size
+		0x10, // etype-int32
+		'K', 'n', 'o', 'w', 'n', 0x00,
+		0x02, 0x00, 0x00, 0x00,
+		0x00,
+	}
+	_, err = Marshal(WithRaw{Known: 1, Rest: conflicting})
+	assert.NotNil(t, err)
+
+	_, err = Marshal(WithRaw{Rest: Raw{0x05, 0x00, 0x00, 0x00, 0x01}}) // Not terminated
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,318 @@
+package ezbson
+
+import (
+	bytelib "bytes"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// RegisterStdlibCodecs registers encode and decode functions for common standard library types,
+// which round-trip exactly:
+//
+//	// +-----------------------------+---------------------------------------------------------------------+
+//	// | golang type                 | BSON type                                                           |
+//	// +-----------------------------+---------------------------------------------------------------------+
+//	// | time.Duration               | int64 (nanoseconds)                                                 |
+//	// | big.Int, *big.Int           | string (decimal, e.g. "-12345678901234567890")                      |
+//	// | big.Float, *big.Float       | document (e.g. {value: "0.5", prec: 53, mode: "ToNearestEven"}) (*) |
+//	// | big.Rat, *big.Rat           | string (e.g. "1/3", or "5" for integers)                            |
+//	// | net.IP                      | binary (the 4 or 16 bytes of the address) (**)                      |
+//	// | netip.Addr                  | string (e.g. "10.0.0.1" or "fe80::1%eth0")                          |
+//	// | netip.Prefix                | string (e.g. "10.0.0.0/8")                                          |
+//	// | url.URL, *url.URL           | string (e.g. "https://example.com/path?q=1")                        |
+//	// | *time.Location              | string (the IANA name, e.g. "America/New_York", or "UTC")           |
+//	// +-----------------------------+---------------------------------------------------------------------+
+//
+// (*) The value is the shortest decimal string that is parsed back into the same big.Float at its precision
+// (see big.Float.Text with 'g' and -1), and the precision and rounding mode are kept next to it.
+// A plain decimal string (e.g. "0.5") can also be decoded, with 64 bits of precision.
+//
+// (**) A net.IP can also be decoded from a string (e.g. "10.0.0.1"), as a 16-byte address.
+//
+// *time.Location is decoded with time.LoadLocation, so it must be a location that can be loaded by name.
+// Encoding any other location (e.g. a time.FixedZone) returns an error.
+//
+// Usage:
+//
+//	registry, err := ezbson.NewRegistryBuilder().RegisterStdlibCodecs().Build()
+//	enc := ezbson.Encoder{Registry: registry}
+//	dec := ezbson.Decoder{Registry: registry}
+func (rb *RegistryBuilder) RegisterStdlibCodecs() *RegistryBuilder {
+	// Encoders are registered for pointer types (which also match values, see findMarshaler),
+	// and decoders for value types (which also match pointers, as they are allocated before decoding).
+	return rb.
+		RegisterTypeEncoder(reflect.TypeOf(time.Duration(0)), encodeDuration).
+		RegisterTypeDecoder(reflect.TypeOf(time.Duration(0)), decodeDuration).
+		RegisterTypeEncoder(reflect.TypeOf(&big.Int{}), encodeBigInt).
+		RegisterTypeDecoder(reflect.TypeOf(big.Int{}), decodeBigInt).
+		RegisterTypeEncoder(reflect.TypeOf(&big.Float{}), encodeBigFloat).
+		RegisterTypeDecoder(reflect.TypeOf(big.Float{}), decodeBigFloat).
+		RegisterTypeEncoder(reflect.TypeOf(&big.Rat{}), encodeBigRat).
+		RegisterTypeDecoder(reflect.TypeOf(big.Rat{}), decodeBigRat).
+		RegisterTypeEncoder(reflect.TypeOf(net.IP{}), encodeIP).
+		RegisterTypeDecoder(reflect.TypeOf(net.IP{}), decodeIP).
+		RegisterTypeEncoder(reflect.TypeOf(netip.Addr{}), encodeAddr).
+		RegisterTypeDecoder(reflect.TypeOf(netip.Addr{}), decodeAddr).
+		RegisterTypeEncoder(reflect.TypeOf(netip.Prefix{}), encodePrefix).
+		RegisterTypeDecoder(reflect.TypeOf(netip.Prefix{}), decodePrefix).
+		RegisterTypeEncoder(reflect.TypeOf(&url.URL{}), encodeURL).
+		RegisterTypeDecoder(reflect.TypeOf(url.URL{}), decodeURL).
+		RegisterTypeEncoder(reflect.TypeOf(&time.Location{}), encodeLocation).
+		RegisterTypeDecoder(reflect.TypeOf(&time.Location{}), decodeLocation) // A time.Location must not be copied
+}
+
+func encodeDuration(val any) (byte, []byte, error) {
+	data, err := appendInt64(nil, int64(val.(time.Duration)))
+	return byte(kEtypeInt64), data, err
+}
+
+func decodeDuration(et byte, data []byte, ptr any) error {
+	switch etype(et) {
+	case kEtypeInt64:
+		var val int64
+		if _, err := readInt64(bytelib.NewBuffer(data), &val); err != nil {
+			return err
+		}
+		*ptr.(*time.Duration) = time.Duration(val)
+	case kEtypeInt32:
+		var val int32
+		if _, err := readInt32(bytelib.NewBuffer(data), &val); err != nil {
+			return err
+		}
+		*ptr.(*time.Duration) = time.Duration(val)
+	default:
+		return fmt.Errorf("cannot convert etype %v to time.Duration (only int32 and int64 are supported)", et)
+	}
+
+	return nil
+}
+
+func encodeBigInt(val any) (byte, []byte, error) {
+	return encodeStdlibString(val.(*big.Int).String())
+}
+
+func decodeBigInt(et byte, data []byte, ptr any) error {
+	s, err := decodeStdlibString(et, data)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ptr.(*big.Int).SetString(s, 10); !ok {
+		return fmt.Errorf("invalid big.Int %q", s)
+	}
+	return nil
+}
+
+// bigRoundingModes are the big.RoundingMode values, by name (e.g. "ToNearestEven").
+var bigRoundingModes = map[string]big.RoundingMode{}
+
+func init() {
+	for _, mode := range []big.RoundingMode{big.ToNearestEven, big.ToNearestAway, big.ToZero, big.AwayFromZero, big.ToNegativeInf, big.ToPositiveInf} {
+		bigRoundingModes[mode.String()] = mode
+	}
+}
+
+// bigFloatDocument is how a big.Float is marshalled: its value as a decimal string, next to its precision and rounding mode.
+type bigFloatDocument struct {
+	Value string `bson:"value"`
+	Prec  int64  `bson:"prec"`
+	Mode  string `bson:"mode"`
+}
+
+// encodeBigFloat writes a big.Float as a bigFloatDocument (e.g. {value: "0.5", prec: 53, mode: "ToNearestEven"}).
+// The shortest decimal that identifies the value at its precision is used, so the value round-trips exactly.
+func encodeBigFloat(val any) (byte, []byte, error) {
+	f := val.(*big.Float)
+	data, err := Marshal(bigFloatDocument{Value: f.Text('g', -1), Prec: int64(f.Prec()), Mode: f.Mode().String()})
+	return byte(kEtypeDocument), data, err
+}
+
+func decodeBigFloat(et byte, data []byte, ptr any) error {
+	f := ptr.(*big.Float)
+
+	if etype(et) == kEtypeString { // A plain decimal string
+		s, err := decodeStdlibString(et, data)
+		if err != nil {
+			return err
+		}
+		if _, ok := f.SetPrec(64).SetString(s); !ok {
+			return fmt.Errorf("invalid big.Float %q", s)
+		}
+		return nil
+	}
+	if etype(et) != kEtypeDocument {
+		return fmt.Errorf("cannot convert etype %v to big.Float (only document and string are supported)", et)
+	}
+
+	var doc bigFloatDocument
+	if err := (&Decoder{DisallowUnknownFields: true}).Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid big.Float: %w", err)
+	}
+
+	if doc.Prec < 0 || doc.Prec > big.MaxPrec {
+		return fmt.Errorf("invalid big.Float precision %v", doc.Prec)
+	}
+	mode, ok := bigRoundingModes[doc.Mode]
+	if !ok {
+		return fmt.Errorf("invalid big.Float rounding mode %q", doc.Mode)
+	}
+
+	// The shortest decimal is parsed back into the same value when rounding to nearest even
+	if _, _, err := f.SetPrec(uint(doc.Prec)).SetMode(big.ToNearestEven).Parse(doc.Value, 10); err != nil {
+		return fmt.Errorf("invalid big.Float %q: %w", doc.Value, err)
+	}
+	f.SetMode(mode)
+	if doc.Prec == 0 {
+		f.SetPrec(0) // Parse uses 64 bits when the precision is 0 (only ±0 and ±Inf have a precision of 0)
+	}
+	return nil
+}
+
+func encodeBigRat(val any) (byte, []byte, error) {
+	return encodeStdlibString(val.(*big.Rat).RatString())
+}
+
+func decodeBigRat(et byte, data []byte, ptr any) error {
+	s, err := decodeStdlibString(et, data)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ptr.(*big.Rat).SetString(s); !ok {
+		return fmt.Errorf("invalid big.Rat %q", s)
+	}
+	return nil
+}
+
+func encodeIP(val any) (byte, []byte, error) {
+	data, err := appendBinary(nil, Binary{Subtype: BinarySubtypeGeneric, Data: val.(net.IP)})
+	return byte(kEtypeBinary), data, err
+}
+
+func decodeIP(et byte, data []byte, ptr any) error {
+	switch etype(et) {
+	case kEtypeBinary:
+		var val Binary
+		if _, err := readEbinary(bytelib.NewBuffer(data), &val); err != nil {
+			return err
+		}
+		if len(val.Data) != net.IPv4len && len(val.Data) != net.IPv6len {
+			return fmt.Errorf("invalid net.IP length (%v bytes)", len(val.Data))
+		}
+		*ptr.(*net.IP) = val.Data // readEbinary copies the data
+
+	case kEtypeString:
+		var s string
+		if _, err := readEstring(bytelib.NewBuffer(data), &s); err != nil {
+			return err
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("invalid net.IP %q", s)
+		}
+		*ptr.(*net.IP) = ip
+
+	default:
+		return fmt.Errorf("cannot convert etype %v to net.IP (only binary and string are supported)", et)
+	}
+
+	return nil
+}
+
+func encodeAddr(val any) (byte, []byte, error) {
+	text, _ := val.(netip.Addr).MarshalText() // Never fails
+	return encodeStdlibString(string(text))
+}
+
+func decodeAddr(et byte, data []byte, ptr any) error {
+	s, err := decodeStdlibString(et, data)
+	if err != nil {
+		return err
+	}
+
+	return ptr.(*netip.Addr).UnmarshalText([]byte(s))
+}
+
+func encodePrefix(val any) (byte, []byte, error) {
+	text, _ := val.(netip.Prefix).MarshalText() // Never fails
+	return encodeStdlibString(string(text))
+}
+
+func decodePrefix(et byte, data []byte, ptr any) error {
+	s, err := decodeStdlibString(et, data)
+	if err != nil {
+		return err
+	}
+
+	return ptr.(*netip.Prefix).UnmarshalText([]byte(s))
+}
+
+func encodeURL(val any) (byte, []byte, error) {
+	return encodeStdlibString(val.(*url.URL).String())
+}
+
+func decodeURL(et byte, data []byte, ptr any) error {
+	s, err := decodeStdlibString(et, data)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*ptr.(*url.URL) = *parsed
+	return nil
+}
+
+// loadableLocations caches the names of locations that time.LoadLocation has loaded (see encodeLocation).
+var loadableLocations sync.Map // string -> bool
+
+func encodeLocation(val any) (byte, []byte, error) {
+	name := val.(*time.Location).String()
+	if _, ok := loadableLocations.Load(name); !ok {
+		if _, err := time.LoadLocation(name); err != nil { // e.g. a time.FixedZone, which couldn't be decoded
+			return 0, nil, fmt.Errorf("location %q cannot be loaded by name: %w", name, err)
+		}
+		loadableLocations.Store(name, true)
+	}
+
+	return encodeStdlibString(name)
+}
+
+func decodeLocation(et byte, data []byte, ptr any) error {
+	s, err := decodeStdlibString(et, data)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return err
+	}
+
+	*ptr.(**time.Location) = loc
+	return nil
+}
+
+func encodeStdlibString(s string) (byte, []byte, error) {
+	data, err := appendString(nil, s)
+	return byte(kEtypeString), data, err
+}
+
+func decodeStdlibString(et byte, data []byte) (string, error) {
+	if etype(et) != kEtypeString {
+		return "", fmt.Errorf("cannot convert etype %v to this type (only string is supported)", et)
+	}
+
+	var s string
+	_, err := readEstring(bytelib.NewBuffer(data), &s)
+	return s, err
+}
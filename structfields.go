@@ -0,0 +1,345 @@
+package ezbson
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structField describes how a (possibly promoted) golang struct field maps to a BSON element.
+type structField struct {
+	name  string // the ename
+	path  string // e.g. "Inner.X" (only used for error messages)
+	index []int  // see reflect.Value.FieldByIndex
+	depth int    // the amount of inlined structs the field is nested in
+
+	omitEmpty  bool       // see bsonTag
+	minSize    bool       // see bsonTag
+	timeFormat timeFormat // see bsonTag
+}
+
+// structFields describes how a golang struct type maps to a BSON document.
+type structFields struct {
+	list      []structField  // sorted by declaration order
+	byName    map[string]int // ename -> position in list
+	byFold    map[string]int // lowercase ename -> position in list (of the first such field), for case-insensitive matching
+	remainder *structField   // the inline map (or remainder field) that collects unmatched elements (nil if there is none)
+
+	unexported map[string]bool // The names of unexported fields (which are skipped)
+}
+
+// bsonTag is the parsed `bson:"name,option1,option2..."` struct tag.
+type bsonTag struct {
+	name      string // The ename (the golang field name is used if empty)
+	skip      bool   // `bson:"-"`: the field is neither marshalled nor unmarshalled
+	inline    bool   // The field is flattened into the parent document (see getStructFields)
+	remainder bool   // The field (a map or Raw) collects the elements that don't match any other field
+	omitEmpty bool   // The field is not marshalled if it is empty (see isEmptyValue)
+	minSize   bool   // int64 values that fit are marshalled as int32
+
+	timeFormat timeFormat // unixnano or rfc3339nano: how a time.Time (or *time.Time) field is marshalled
+}
+
+func parseBsonTag(tag string) bsonTag {
+	var result bsonTag
+
+	if tag == "-" { // Note that `bson:"-,"` is a field named "-"
+		result.skip = true
+		return result
+	}
+
+	options := strings.Split(tag, ",")
+	result.name = options[0]
+	for _, option := range options[1:] {
+		switch option {
+		case "inline":
+			result.inline = true
+		case "remainder":
+			result.remainder = true
+		case "omitempty":
+			result.omitEmpty = true
+		case "minsize":
+			result.minSize = true
+		case "unixnano":
+			result.timeFormat = kTimeFormatUnixNano
+		case "rfc3339nano":
+			result.timeFormat = kTimeFormatRFC3339Nano
+		}
+	}
+
+	return result
+}
+
+// getStructFields flattens the fields of the struct type rtype, like encoding/json does:
+//   - the fields of embedded structs (and pointers to structs) are promoted into the parent document.
+//   - fields tagged with `bson:",inline"` are promoted as well. An inline map[...]... collects the elements
+//     that don't match any other field, as does a field tagged with `bson:",remainder"` (a map[...]... or Raw).
+//   - a shallower field hides deeper fields with the same name. An error is returned if two fields with the same name
+//     are at the same depth, or if there is more than one inline map (or remainder field).
+//   - fields are named by their `bson:"name"` tag (or by their golang name, converted by naming),
+//     and fields tagged `bson:"-"` are skipped.
+//   - unexported fields are skipped (but the exported fields of an embedded unexported struct are promoted).
+//
+// Like encoding/json, the result is cached per type (and naming strategy), so it must not be modified.
+func getStructFields(rtype reflect.Type, naming NamingStrategy) (*structFields, error) {
+	key := structFieldsKey{rtype: rtype, naming: naming}
+	if cached, ok := structFieldsCache.Load(key); ok {
+		entry := cached.(structFieldsEntry)
+		return entry.fields, entry.err
+	}
+
+	fields, err := computeStructFields(rtype, naming)
+	cached, _ := structFieldsCache.LoadOrStore(key, structFieldsEntry{fields: fields, err: err})
+	entry := cached.(structFieldsEntry)
+	return entry.fields, entry.err
+}
+
+type structFieldsKey struct {
+	rtype  reflect.Type
+	naming NamingStrategy
+}
+
+type structFieldsEntry struct {
+	fields *structFields
+	err    error
+}
+
+var structFieldsCache sync.Map // structFieldsKey -> structFieldsEntry
+
+// computeStructFields implements getStructFields (without the cache).
+func computeStructFields(rtype reflect.Type, naming NamingStrategy) (*structFields, error) {
+	candidates := make([]structField, 0)
+	var remainder *structField
+	unexported := make(map[string]bool)
+
+	err := collectStructFields(rtype, naming, nil, "", 0, map[reflect.Type]bool{}, &candidates, &remainder, unexported)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &structFields{
+		list:       make([]structField, 0, len(candidates)),
+		byName:     make(map[string]int),
+		byFold:     make(map[string]int),
+		remainder:  remainder,
+		unexported: unexported,
+	}
+
+	dominant := make(map[string]structField)
+	for _, field := range candidates {
+		other, ok := dominant[field.name]
+		switch {
+		case !ok || field.depth < other.depth:
+			dominant[field.name] = field
+		case field.depth == other.depth:
+			return nil, fmt.Errorf("%v: fields %v and %v conflict on key {%v}", rtype, other.path, field.path, field.name)
+		}
+	}
+
+	for _, field := range candidates { // candidates are in declaration order
+		if dominant[field.name].path != field.path {
+			continue
+		}
+		result.byName[field.name] = len(result.list)
+		if _, ok := result.byFold[strings.ToLower(field.name)]; !ok {
+			result.byFold[strings.ToLower(field.name)] = len(result.list)
+		}
+		result.list = append(result.list, field)
+	}
+
+	return result, nil
+}
+
+// lookup returns the field named ename. If caseInsensitive is set and there is no exact match,
+// the first field whose name matches ename case-insensitively is returned.
+func (fields *structFields) lookup(ename string, caseInsensitive bool) (*structField, bool) {
+	pos, ok := fields.byName[ename]
+	if !ok && caseInsensitive {
+		pos, ok = fields.byFold[strings.ToLower(ename)]
+	}
+	if !ok {
+		return nil, false
+	}
+
+	return &fields.list[pos], true
+}
+
+func collectStructFields(
+	rtype reflect.Type,
+	naming NamingStrategy,
+	index []int,
+	pathPrefix string,
+	depth int,
+	visiting map[reflect.Type]bool,
+	candidates *[]structField,
+	remainder **structField,
+	unexported map[string]bool,
+) error {
+	if visiting[rtype] { // e.g. `type A struct { *A }`
+		return fmt.Errorf("%v: recursive inline struct", rtype)
+	}
+	visiting[rtype] = true
+	defer delete(visiting, rtype)
+
+	for i := 0; i < rtype.NumField(); i++ {
+		sf := rtype.Field(i)
+		tag := parseBsonTag(sf.Tag.Get("bson"))
+		if tag.skip {
+			continue
+		}
+
+		field := structField{
+			name:       naming.apply(sf.Name),
+			path:       pathPrefix + sf.Name,
+			index:      append(append([]int{}, index...), i),
+			depth:      depth,
+			omitEmpty:  tag.omitEmpty,
+			minSize:    tag.minSize,
+			timeFormat: tag.timeFormat,
+		}
+		if tag.name != "" {
+			field.name = tag.name
+		}
+
+		fieldRtype := sf.Type
+		if fieldRtype.Kind() == reflect.Pointer {
+			fieldRtype = fieldRtype.Elem()
+		}
+
+		if !sf.IsExported() {
+			// reflect can't access unexported fields. The exception is the exported fields of an embedded unexported struct,
+			// unless it is a pointer (which can't be allocated on decode).
+			if !sf.Anonymous || sf.Type.Kind() == reflect.Pointer || !isDocumentStructRtype(sf.Type) {
+				unexported[field.name] = true
+				continue
+			}
+		}
+
+		if tag.timeFormat != kTimeFormatDatetime && fieldRtype != reflect.TypeOf(time.Time{}) {
+			return fmt.Errorf("%v: field %v has a time format tag, but is not a time.Time (or *time.Time)", rtype, field.path)
+		}
+
+		switch {
+		case (tag.inline && sf.Type.Kind() == reflect.Map) || tag.remainder:
+			if tag.remainder && sf.Type.Kind() != reflect.Map && sf.Type != reflect.TypeOf(Raw{}) {
+				return fmt.Errorf("%v: remainder field %v must be a map or Raw", rtype, field.path)
+			}
+			if *remainder != nil {
+				return fmt.Errorf("%v: more than one inline map or remainder field (%v and %v)", rtype, (*remainder).path, field.path)
+			}
+			*remainder = &field
+
+		case (tag.inline || (sf.Anonymous && tag.name == "")) && isDocumentStructRtype(fieldRtype): // Like encoding/json, a tag name prevents flattening
+			err := collectStructFields(fieldRtype, naming, field.index, field.path+".", depth+1, visiting, candidates, remainder, unexported)
+			if err != nil {
+				return err
+			}
+
+		case tag.inline:
+			return fmt.Errorf("%v: inline field %v must be a struct, a pointer to a struct, or a map", rtype, field.path)
+
+		default:
+			*candidates = append(*candidates, field)
+		}
+	}
+
+	return nil
+}
+
+// isDocumentStructRtype reports whether rtype is a struct that is serialized as a document
+// (and not one of the struct types that have their own etype, such as time.Time or Timestamp).
+func isDocumentStructRtype(rtype reflect.Type) bool {
+	if rtype.Kind() != reflect.Struct {
+		return false
+	}
+
+	et, err := (&Encoder{AllowDeprecatedTypes: true}).getEtype(reflect.Zero(rtype).Interface())
+	return err == nil && et == kEtypeDocument
+}
+
+// isEmptyValue reports whether rvalue is omitted by the omitempty tag option.
+// Like encoding/json, false, 0, nil and empty arrays, maps, slices and strings are empty.
+// In addition, values that have an `IsZero() bool` method (such as time.Time and ObjectID) are empty if it returns true.
+func isEmptyValue(rvalue reflect.Value) bool {
+	if rvalue.Kind() == reflect.Pointer || rvalue.Kind() == reflect.Interface {
+		if rvalue.IsNil() {
+			return true // Also avoids calling IsZero on a nil pointer
+		}
+	}
+
+	if zeroer, ok := rvalue.Interface().(interface{ IsZero() bool }); ok {
+		return zeroer.IsZero()
+	}
+
+	switch rvalue.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rvalue.Len() == 0
+	case reflect.Bool:
+		return !rvalue.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rvalue.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rvalue.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rvalue.Float() == 0
+	default:
+		return false
+	}
+}
+
+// minSizeValue returns rvalue (or the value that it points to) as an int32 if it is marshalled as an int64 (see getEtype)
+// and fits in an int32. Otherwise, rvalue is returned as-is.
+func minSizeValue(rvalue reflect.Value) any {
+	elem := rvalue
+	if elem.Kind() == reflect.Pointer && !elem.IsNil() {
+		elem = elem.Elem() // A non-nil *int64 is marshalled like an int64
+	}
+
+	switch elem.Kind() {
+	case reflect.Int, reflect.Int64:
+		if val := elem.Int(); val >= math.MinInt32 && val <= math.MaxInt32 {
+			return int32(val)
+		}
+	case reflect.Uint32, reflect.Uint, reflect.Uint64:
+		if val := elem.Uint(); val <= math.MaxInt32 {
+			return int32(val)
+		}
+	}
+
+	return rvalue.Interface()
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but returns false (instead of panicking)
+// if one of the inlined structs on the way is a nil pointer.
+func fieldByIndex(rvalue reflect.Value, index []int) (reflect.Value, bool) {
+	for i, fieldIndex := range index {
+		if i > 0 && rvalue.Kind() == reflect.Pointer {
+			if rvalue.IsNil() {
+				return reflect.Value{}, false
+			}
+			rvalue = rvalue.Elem()
+		}
+		rvalue = rvalue.Field(fieldIndex)
+	}
+
+	return rvalue, true
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates the inlined structs on the way
+// that are nil pointers. rvalue must be settable.
+func fieldByIndexAlloc(rvalue reflect.Value, index []int) reflect.Value {
+	for i, fieldIndex := range index {
+		if i > 0 && rvalue.Kind() == reflect.Pointer {
+			if rvalue.IsNil() {
+				rvalue.Set(reflect.New(rvalue.Type().Elem()))
+			}
+			rvalue = rvalue.Elem()
+		}
+		rvalue = rvalue.Field(fieldIndex)
+	}
+
+	return rvalue
+}
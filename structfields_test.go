@@ -0,0 +1,185 @@
+package ezbson
+
+import (
+	"reflect"
+	"testing"
+	timelib "time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type StructFieldsBase struct {
+	ID   int32
+	Name string
+}
+
+type StructFieldsOther struct {
+	Name string
+}
+
+func TestGetStructFieldsEmbedded(t *testing.T) {
+	type S struct {
+		StructFieldsBase
+		Name  string         // Hides StructFieldsBase.Name
+		Extra map[string]any `bson:",inline"`
+	}
+
+	fields, err := getStructFields(reflect.TypeOf(S{}), NamingGo)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.Len(t, fields.list, 2) {
+		return
+	}
+	assert.Equal(t, "ID", fields.list[0].name)
+	assert.Equal(t, []int{0, 0}, fields.list[0].index)
+	assert.Equal(t, "Name", fields.list[1].name)
+	assert.Equal(t, []int{1}, fields.list[1].index)
+
+	if !assert.NotNil(t, fields.remainder) {
+		return
+	}
+	assert.Equal(t, []int{2}, fields.remainder.index)
+}
+
+func TestGetStructFieldsErrors(t *testing.T) {
+	type Conflict struct {
+		StructFieldsBase
+		*StructFieldsOther
+	}
+	type TwoInlineMaps struct {
+		A map[string]any `bson:",inline"`
+		B map[string]any `bson:",inline"`
+	}
+	type BadInline struct {
+		A int32 `bson:",inline"`
+	}
+	type Recursive struct {
+		*Recursive
+	}
+	type BadRemainder struct {
+		Rest []byte `bson:",remainder"`
+	}
+	type InlineMapAndRemainder struct {
+		A map[string]any `bson:",inline"`
+		B Raw            `bson:",remainder"`
+	}
+
+	for _, rtype := range []reflect.Type{
+		reflect.TypeOf(Conflict{}),
+		reflect.TypeOf(TwoInlineMaps{}),
+		reflect.TypeOf(BadInline{}),
+		reflect.TypeOf(Recursive{}),
+		reflect.TypeOf(BadRemainder{}),
+		reflect.TypeOf(InlineMapAndRemainder{}),
+	} {
+		_, err := getStructFields(rtype, NamingGo)
+		assert.NotNil(t, err, rtype.String())
+	}
+}
+
+func TestGetStructFieldsCache(t *testing.T) {
+	type Cached struct {
+		UserID int32
+	}
+
+	first, err := getStructFields(reflect.TypeOf(Cached{}), NamingGo)
+	if !assert.Nil(t, err) {
+		return
+	}
+	second, err := getStructFields(reflect.TypeOf(Cached{}), NamingGo)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Same(t, first, second)
+
+	// The naming strategy is part of the cache key
+	snake, err := getStructFields(reflect.TypeOf(Cached{}), NamingSnakeCase)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "user_id", snake.list[0].name)
+	}
+
+	// Errors are cached as well
+	type BadInline struct {
+		A int32 `bson:",inline"`
+	}
+	_, err = getStructFields(reflect.TypeOf(BadInline{}), NamingGo)
+	assert.NotNil(t, err)
+	_, err = getStructFields(reflect.TypeOf(BadInline{}), NamingGo)
+	assert.NotNil(t, err)
+}
+
+func TestGetStructFieldsSpecialStructsAreNotFlattened(t *testing.T) {
+	type S struct {
+		Timestamp
+		Decimal128
+	}
+
+	fields, err := getStructFields(reflect.TypeOf(S{}), NamingGo)
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Len(t, fields.list, 2) {
+		return
+	}
+	assert.Equal(t, "Timestamp", fields.list[0].name)
+	assert.Equal(t, "Decimal128", fields.list[1].name)
+}
+
+type structFieldsUnexported struct {
+	Promoted int32
+}
+
+func TestGetStructFieldsUnexported(t *testing.T) {
+	type S struct {
+		structFieldsUnexported // The exported fields of an embedded unexported struct are promoted
+		Public                 int32
+		private                int32
+	}
+
+	fields, err := getStructFields(reflect.TypeOf(S{}), NamingGo)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	if !assert.Len(t, fields.list, 2) {
+		return
+	}
+	assert.Equal(t, "Promoted", fields.list[0].name)
+	assert.Equal(t, "Public", fields.list[1].name)
+	assert.Equal(t, map[string]bool{"private": true}, fields.unexported)
+}
+
+func TestParseBsonTag(t *testing.T) {
+	assert.Equal(t, bsonTag{}, parseBsonTag(""))
+	assert.Equal(t, bsonTag{skip: true}, parseBsonTag("-"))
+	assert.Equal(t, bsonTag{name: "-"}, parseBsonTag("-,"))
+	assert.Equal(t, bsonTag{name: "created_at", omitEmpty: true}, parseBsonTag("created_at,omitempty"))
+	assert.Equal(t, bsonTag{minSize: true, inline: true}, parseBsonTag(",minsize,inline"))
+	assert.Equal(t, bsonTag{name: "ts", timeFormat: kTimeFormatUnixNano}, parseBsonTag("ts,unixnano"))
+	assert.Equal(t, bsonTag{timeFormat: kTimeFormatRFC3339Nano, omitEmpty: true}, parseBsonTag(",rfc3339nano,omitempty"))
+}
+
+type zeroer struct {
+	Val int32
+}
+
+func (z zeroer) IsZero() bool {
+	return z.Val < 0
+}
+
+func TestIsEmptyValue(t *testing.T) {
+	var nilPtr *zeroer
+
+	empty := []any{false, 0, int8(0), uint(0), 0.0, "", []int{}, map[string]any{}, [0]int{}, nilPtr,
+		timelib.Time{}, ObjectID{}, Decimal128{}, zeroer{Val: -1}}
+	for _, val := range empty {
+		assert.True(t, isEmptyValue(reflect.ValueOf(val)), "%#v", val)
+	}
+
+	nonEmpty := []any{true, 1, "a", []int{0}, [1]int{}, &zeroer{}, zeroer{Val: 0}, struct{}{}, NewDecimal128(0x3040000000000000, 1)}
+	for _, val := range nonEmpty {
+		assert.False(t, isEmptyValue(reflect.ValueOf(val)), "%#v", val)
+	}
+}
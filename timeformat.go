@@ -0,0 +1,117 @@
+package ezbson
+
+import (
+	bytelib "bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// timeFormat is the BSON representation of a time.Time struct field, chosen by its tag.
+type timeFormat int
+
+const (
+	kTimeFormatDatetime    timeFormat = iota // The default: UTC datetime (milliseconds since the epoch)
+	kTimeFormatUnixNano                      // `bson:",unixnano"`: int64 (nanoseconds since the epoch)
+	kTimeFormatRFC3339Nano                   // `bson:",rfc3339nano"`: string (time.RFC3339Nano, which keeps the zone offset)
+)
+
+var (
+	minUnixNanoTime = time.Unix(0, math.MinInt64)
+	maxUnixNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+// etype returns the etype that times are marshalled as in format.
+func (format timeFormat) etype() etype {
+	switch format {
+	case kTimeFormatUnixNano:
+		return kEtypeInt64
+	case kTimeFormatRFC3339Nano:
+		return kEtypeString
+	default:
+		return kEtypeUtcDatetime
+	}
+}
+
+// formatTime converts t into the golang value that is marshalled in format (an int64 or a string).
+func formatTime(t time.Time, format timeFormat) (any, error) {
+	switch format {
+	case kTimeFormatUnixNano:
+		if t.Before(minUnixNanoTime) || t.After(maxUnixNanoTime) {
+			return nil, fmt.Errorf("time %v cannot be represented in int64 nanoseconds since the epoch", t)
+		}
+		return t.UnixNano(), nil
+	case kTimeFormatRFC3339Nano:
+		// RFC 3339 (and time.Parse) only allow the years 0000-9999, and zone offsets of whole minutes below 24 hours
+		if t.Year() < 0 || t.Year() > 9999 {
+			return nil, fmt.Errorf("time %v cannot be represented in RFC 3339 (the year must be 0000-9999)", t)
+		}
+		if _, offset := t.Zone(); offset%60 != 0 || offset <= -24*60*60 || offset >= 24*60*60 {
+			return nil, fmt.Errorf("time %v cannot be represented in RFC 3339 (unsupported zone offset %vs)", t, offset)
+		}
+		return t.Format(time.RFC3339Nano), nil
+	default:
+		return t, nil
+	}
+}
+
+// formatTimeField applies format to a time.Time (or *time.Time) field value. nil pointers are returned as-is.
+func formatTimeField(rvalue reflect.Value, format timeFormat) (any, error) {
+	if rvalue.Kind() == reflect.Pointer {
+		if rvalue.IsNil() {
+			return rvalue.Interface(), nil
+		}
+		rvalue = rvalue.Elem()
+	}
+
+	return formatTime(rvalue.Interface().(time.Time), format)
+}
+
+// readTimeEvalue reads an evalue of format.etype() into rvalue (a time.Time, or a *time.Time which is allocated as needed).
+func (dec *Decoder) readTimeEvalue(buffer *bytelib.Buffer, rvalue reflect.Value, format timeFormat) (numread int, err error) {
+	var t time.Time
+
+	switch format {
+	case kTimeFormatUnixNano:
+		var nanosecFromEpoch int64
+		if numread, err = readInt64(buffer, &nanosecFromEpoch); err != nil {
+			return 0, err
+		}
+		t = time.Unix(0, nanosecFromEpoch).In(dec.timeLocation())
+
+	case kTimeFormatRFC3339Nano:
+		var s string
+		if numread, err = readEstring(buffer, &s); err != nil {
+			return 0, err
+		}
+		if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return 0, err
+		}
+		if dec.TimeLocation != nil { // Otherwise, the parsed zone offset is kept
+			t = t.In(dec.TimeLocation)
+		}
+
+	default:
+		return 0, fmt.Errorf("unexpected time format %v", format)
+	}
+
+	if rvalue.Kind() == reflect.Pointer {
+		if rvalue.IsNil() {
+			rvalue.Set(reflect.New(rvalue.Type().Elem()))
+		}
+		rvalue = rvalue.Elem()
+	}
+	rvalue.Set(reflect.ValueOf(t))
+
+	return numread, nil
+}
+
+// timeLocation returns the location that decoded times are converted into (see Decoder.TimeLocation).
+func (dec *Decoder) timeLocation() *time.Location {
+	if dec.TimeLocation == nil {
+		return time.UTC
+	}
+
+	return dec.TimeLocation
+}
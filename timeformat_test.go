@@ -0,0 +1,145 @@
+package ezbson
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisallowTimePrecisionLoss(t *testing.T) {
+	micros := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	millis := time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+
+	// By default, times are truncated to milliseconds
+	marshalled, err := Marshal(map[string]any{"t": micros})
+	if !assert.Nil(t, err) {
+		return
+	}
+	actual := make(map[string]time.Time)
+	if assert.Nil(t, Unmarshal(marshalled, &actual)) {
+		assert.Equal(t, millis, actual["t"])
+	}
+
+	enc := Encoder{DisallowTimePrecisionLoss: true}
+	_, err = enc.Marshal(map[string]any{"t": micros})
+	assert.NotNil(t, err)
+
+	_, err = enc.Marshal(map[string]any{"t": millis})
+	assert.Nil(t, err)
+
+	// Tagged fields keep their precision
+	_, err = enc.Marshal(struct {
+		T time.Time `bson:"t,unixnano"`
+	}{T: micros})
+	assert.Nil(t, err)
+}
+
+type timeFormatStruct struct {
+	Nano    time.Time  `bson:"nano,unixnano"`
+	Text    *time.Time `bson:"text,rfc3339nano"`
+	NilText *time.Time `bson:"nil_text,rfc3339nano"`
+	Empty   time.Time  `bson:"empty,unixnano,omitempty"`
+}
+
+func TestTimeFormatTags(t *testing.T) {
+	zone := time.FixedZone("UTC+3", 3*60*60)
+	nano := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	text := time.Date(2024, 5, 6, 10, 8, 9, 1, zone)
+
+	marshalled, err := Marshal(timeFormatStruct{Nano: nano, Text: &text})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	asMap := make(map[string]any)
+	if assert.Nil(t, Unmarshal(marshalled, &asMap)) {
+		assert.Equal(t, map[string]any{
+			"nano":     nano.UnixNano(),
+			"text":     "2024-05-06T10:08:09.000000001+03:00",
+			"nil_text": nil,
+		}, asMap)
+	}
+
+	actual := timeFormatStruct{}
+	if !assert.Nil(t, Unmarshal(marshalled, &actual)) {
+		return
+	}
+	assert.Equal(t, nano, actual.Nano)
+	assert.True(t, text.Equal(*actual.Text))
+	_, offset := actual.Text.Zone()
+	assert.Equal(t, 3*60*60, offset) // The zone offset is kept
+	assert.Equal(t, "2024-05-06T10:08:09.000000001+03:00", actual.Text.Format(time.RFC3339Nano))
+	assert.Nil(t, actual.NilText)
+
+	// A UTC datetime is also accepted by a tagged field
+	millis := time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+	marshalled, err = Marshal(map[string]any{"nano": millis, "text": millis})
+	if !assert.Nil(t, err) {
+		return
+	}
+	actual = timeFormatStruct{}
+	if assert.Nil(t, Unmarshal(marshalled, &actual)) {
+		assert.Equal(t, millis, actual.Nano)
+		assert.Equal(t, millis, *actual.Text)
+	}
+}
+
+func TestTimeFormatErrors(t *testing.T) {
+	_, err := Marshal(struct {
+		T int64 `bson:"t,unixnano"`
+	}{})
+	assert.NotNil(t, err)
+
+	_, err = Marshal(struct {
+		T time.Time `bson:"t,unixnano"`
+	}{T: time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)}) // Beyond the int64 nanoseconds range
+	assert.NotNil(t, err)
+
+	for _, text := range []time.Time{
+		time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1800, 1, 1, 0, 0, 0, 0, time.FixedZone("LMT", -(4*60*60+56*60+2))), // Offsets have no seconds in RFC 3339
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("", 25*60*60)),
+	} {
+		_, err = Marshal(struct {
+			T time.Time `bson:"t,rfc3339nano"`
+		}{T: text})
+		assert.NotNil(t, err, text.String())
+	}
+
+	marshalled, err := Marshal(map[string]any{"text": "yesterday"})
+	if assert.Nil(t, err) {
+		assert.NotNil(t, Unmarshal(marshalled, &timeFormatStruct{}))
+	}
+}
+
+func TestDecoderTimeLocation(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	expected := time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+
+	marshalled, err := Marshal(map[string]any{"t": expected, "nano": expected})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	actual := make(map[string]time.Time)
+	if assert.Nil(t, Unmarshal(marshalled, &actual)) {
+		assert.Equal(t, time.UTC, actual["t"].Location())
+	}
+
+	asAny := make(map[string]any)
+	if assert.Nil(t, (&Decoder{TimeLocation: zone}).Unmarshal(marshalled, &asAny)) {
+		assert.Equal(t, expected.In(zone), asAny["t"])
+	}
+
+	marshalled, err = Marshal(timeFormatStruct{Nano: expected, Text: &expected})
+	if !assert.Nil(t, err) {
+		return
+	}
+	tagged := timeFormatStruct{}
+	if assert.Nil(t, (&Decoder{TimeLocation: time.Local}).Unmarshal(marshalled, &tagged)) {
+		assert.Equal(t, expected.In(time.Local), tagged.Nano)
+		assert.Equal(t, expected.In(time.Local), *tagged.Text)
+	}
+}
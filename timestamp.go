@@ -0,0 +1,59 @@
+package ezbson
+
+import (
+	timelib "time"
+)
+
+// Timestamp is the BSON (MongoDB-internal) timestamp type (etype 17), as used in the oplog.
+// For regular dates, use time.Time (which is serialized as a UTC datetime).
+//
+// It is serialized as a uint64, whose high 32 bits are T and whose low 32 bits are I.
+type Timestamp struct {
+	T uint32 // seconds since the unix epoch
+	I uint32 // an ordinal for operations within the same second
+}
+
+// Time returns the seconds part of ts (T) as a UTC time.Time.
+func (ts Timestamp) Time() timelib.Time {
+	return timelib.Unix(int64(ts.T), 0).UTC()
+}
+
+// Compare returns -1 if ts is before other, +1 if ts is after other, and 0 if they are equal.
+// T is compared first, and I breaks ties.
+func (ts Timestamp) Compare(other Timestamp) int {
+	switch {
+	case ts.T < other.T:
+		return -1
+	case ts.T > other.T:
+		return 1
+	case ts.I < other.I:
+		return -1
+	case ts.I > other.I:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Before reports whether ts is before other.
+func (ts Timestamp) Before(other Timestamp) bool {
+	return ts.Compare(other) < 0
+}
+
+// After reports whether ts is after other.
+func (ts Timestamp) After(other Timestamp) bool {
+	return ts.Compare(other) > 0
+}
+
+// IsZero reports whether ts is the zero Timestamp.
+func (ts Timestamp) IsZero() bool {
+	return ts == Timestamp{}
+}
+
+func (ts Timestamp) uint64() uint64 {
+	return uint64(ts.T)<<32 | uint64(ts.I)
+}
+
+func timestampFromUint64(val uint64) Timestamp {
+	return Timestamp{T: uint32(val >> 32), I: uint32(val)}
+}
@@ -0,0 +1,62 @@
+package ezbson
+
+import (
+	"testing"
+	timelib "time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampCompare(t *testing.T) {
+	a := Timestamp{T: 100, I: 2}
+	b := Timestamp{T: 100, I: 3}
+	c := Timestamp{T: 101, I: 1}
+
+	assert.Equal(t, 0, a.Compare(a))
+	assert.Equal(t, -1, a.Compare(b))
+	assert.Equal(t, 1, c.Compare(b))
+	assert.True(t, a.Before(b))
+	assert.True(t, b.Before(c))
+	assert.True(t, c.After(a))
+	assert.False(t, a.After(a))
+	assert.False(t, a.Before(a))
+}
+
+func TestTimestampTime(t *testing.T) {
+	ts := Timestamp{T: 1136214245, I: 7}
+	assert.Equal(t, timelib.Date(2006, 1, 2, 15, 4, 5, 0, timelib.UTC), ts.Time())
+}
+
+func TestTimestampMarshalUnmarshal(t *testing.T) {
+	kMarshalled := []byte{
+		0x11, 0x00, 0x00, 0x00, // total document size
+		0x11, // etype-timestamp
+		't', 's', 0x00,
+		0x02, 0x00, 0x00, 0x00, // I
+		0x01, 0x00, 0x00, 0x00, // T
+		0x00,
+	}
+
+	ts := Timestamp{T: 1, I: 2}
+
+	marshalled, err := Marshal(map[string]any{"ts": ts})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"ts": ts}, asMap)
+
+	asTypedMap := make(map[string]Timestamp)
+	if err := Unmarshal(kMarshalled, &asTypedMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]Timestamp{"ts": ts}, asTypedMap)
+
+	wrongType := make(map[string]int64)
+	assert.NotNil(t, Unmarshal(kMarshalled, &wrongType))
+}
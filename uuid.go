@@ -0,0 +1,154 @@
+package ezbson
+
+import (
+	cryptorand "crypto/rand"
+	binlib "encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	timelib "time"
+)
+
+// UUID is a RFC 9562 UUID. It is serialized as binary with the UUID subtype (4).
+type UUID [16]byte
+
+const (
+	kUUIDStringLen = 36 // 8-4-4-4-12 hex digits, with hyphens
+
+	kUUIDVersion4 = 4
+	kUUIDVersion7 = 7
+	kUUIDVariant  = 0x80 // The two most significant bits of byte 8 are 10
+)
+
+// uuidHyphens are the positions of the hyphens in the canonical string form.
+var uuidHyphens = [...]int{8, 13, 18, 23}
+
+// ParseUUID parses the canonical 8-4-4-4-12 form of a UUID (e.g. "f81d4fae-7dec-11d0-a765-00a0c91e6bf6").
+// Both lowercase and uppercase hex digits are accepted.
+func ParseUUID(s string) (UUID, error) {
+	var uuid UUID
+
+	if len(s) != kUUIDStringLen {
+		return UUID{}, fmt.Errorf("invalid UUID length (%v)", len(s))
+	}
+
+	hexDigits := make([]byte, 0, 2*len(uuid))
+	prev := 0
+	for _, pos := range uuidHyphens {
+		if s[pos] != '-' {
+			return UUID{}, fmt.Errorf("invalid UUID (%v): expected '-' at position %v", s, pos)
+		}
+		hexDigits = append(hexDigits, s[prev:pos]...)
+		prev = pos + 1
+	}
+	hexDigits = append(hexDigits, s[prev:]...)
+
+	if _, err := hex.Decode(uuid[:], hexDigits); err != nil {
+		return UUID{}, fmt.Errorf("invalid UUID (%v): %w", s, err)
+	}
+
+	return uuid, nil
+}
+
+// String returns the canonical lowercase 8-4-4-4-12 form of uuid.
+func (uuid UUID) String() string {
+	buf := make([]byte, kUUIDStringLen)
+
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+
+	return string(buf)
+}
+
+// Version returns the version of uuid (the most significant 4 bits of byte 6).
+func (uuid UUID) Version() int {
+	return int(uuid[6] >> 4)
+}
+
+// IsZero reports whether uuid is the nil UUID.
+func (uuid UUID) IsZero() bool {
+	return uuid == UUID{}
+}
+
+// MarshalText implements encoding.TextMarshaler (the canonical form is used).
+func (uuid UUID) MarshalText() ([]byte, error) {
+	return []byte(uuid.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler (the canonical form is expected).
+func (uuid *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+
+	*uuid = parsed
+	return nil
+}
+
+// NewUUIDv4 generates a random (version 4) UUID.
+func NewUUIDv4() (UUID, error) {
+	var uuid UUID
+
+	if _, err := cryptorand.Read(uuid[:]); err != nil {
+		return UUID{}, fmt.Errorf("cannot generate UUID: %w", err)
+	}
+
+	setUUIDVersionAndVariant(&uuid, kUUIDVersion4)
+	return uuid, nil
+}
+
+var uuidV7State struct {
+	sync.Mutex
+	lastMilli int64
+	counter   uint16 // 12 bits
+}
+
+// NewUUIDv7 generates a time-ordered (version 7) UUID: 48 bits of milliseconds since the unix epoch,
+// followed by a 12 bit counter and 62 random bits.
+//
+// UUIDs generated by the same process are strictly increasing (the counter is incremented for UUIDs generated
+// in the same millisecond). It is safe for concurrent use.
+func NewUUIDv7() (UUID, error) {
+	var uuid UUID
+
+	if _, err := cryptorand.Read(uuid[:]); err != nil {
+		return UUID{}, fmt.Errorf("cannot generate UUID: %w", err)
+	}
+
+	uuidV7State.Lock()
+	milli := timelib.Now().UnixMilli()
+	if milli <= uuidV7State.lastMilli {
+		uuidV7State.counter++
+		if uuidV7State.counter > 0xfff { // Counter overflow: borrow the next millisecond
+			uuidV7State.lastMilli++
+			uuidV7State.counter = 0
+		}
+		milli = uuidV7State.lastMilli
+	} else {
+		uuidV7State.lastMilli = milli
+		uuidV7State.counter = 0
+	}
+	counter := uuidV7State.counter
+	uuidV7State.Unlock()
+
+	var milliBytes [8]byte
+	binlib.BigEndian.PutUint64(milliBytes[:], uint64(milli))
+	copy(uuid[0:6], milliBytes[2:])
+	binlib.BigEndian.PutUint16(uuid[6:8], counter)
+
+	setUUIDVersionAndVariant(&uuid, kUUIDVersion7)
+	return uuid, nil
+}
+
+func setUUIDVersionAndVariant(uuid *UUID, version byte) {
+	uuid[6] = (uuid[6] & 0x0f) | (version << 4)
+	uuid[8] = (uuid[8] & 0x3f) | kUUIDVariant
+}
@@ -0,0 +1,128 @@
+package ezbson
+
+import (
+	"testing"
+	timelib "time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUIDParseAndString(t *testing.T) {
+	uuid, err := ParseUUID("F81D4FAE-7DEC-11D0-A765-00A0C91E6BF6")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected := UUID{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6}
+	assert.Equal(t, expected, uuid)
+	assert.Equal(t, "f81d4fae-7dec-11d0-a765-00a0c91e6bf6", uuid.String())
+	assert.Equal(t, 1, uuid.Version())
+
+	for _, invalid := range []string{
+		"",
+		"f81d4fae7dec11d0a76500a0c91e6bf6",
+		"f81d4fae-7dec-11d0-a765_00a0c91e6bf6",
+		"g81d4fae-7dec-11d0-a765-00a0c91e6bf6",
+	} {
+		_, err := ParseUUID(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestNewUUIDv4(t *testing.T) {
+	uuid1, err := NewUUIDv4()
+	if !assert.Nil(t, err) {
+		return
+	}
+	uuid2, err := NewUUIDv4()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.NotEqual(t, uuid1, uuid2)
+	assert.Equal(t, 4, uuid1.Version())
+	assert.Equal(t, byte(0x80), uuid1[8]&0xc0)
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	before := timelib.Now().UnixMilli()
+
+	uuids := make([]UUID, 100)
+	for i := range uuids {
+		uuid, err := NewUUIDv7()
+		if !assert.Nil(t, err) {
+			return
+		}
+		uuids[i] = uuid
+	}
+
+	assert.Equal(t, 7, uuids[0].Version())
+	assert.Equal(t, byte(0x80), uuids[0][8]&0xc0)
+
+	milli := int64(uuids[0][0])<<40 | int64(uuids[0][1])<<32 | int64(uuids[0][2])<<24 |
+		int64(uuids[0][3])<<16 | int64(uuids[0][4])<<8 | int64(uuids[0][5])
+	assert.GreaterOrEqual(t, milli, before)
+
+	for i := 1; i < len(uuids); i++ {
+		assert.Less(t, uuids[i-1].String(), uuids[i].String())
+	}
+}
+
+func TestUUIDMarshalUnmarshal(t *testing.T) {
+	uuid := UUID{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6}
+
+	kMarshalled := []byte{
+		0x1d, 0x00, 0x00, 0x00, // total document size
+		0x05, // etype-binary
+		'u', 0x00,
+		0x10, 0x00, 0x00, 0x00, // size
+		0x04, // subtype (uuid)
+		0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6,
+		0x00,
+	}
+
+	marshalled, err := Marshal(map[string]any{"u": uuid})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, kMarshalled, marshalled)
+
+	asMap := make(map[string]any)
+	if err := Unmarshal(kMarshalled, &asMap); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{"u": uuid}, asMap)
+
+	asStruct := struct {
+		U UUID
+	}{}
+	kMarshalled[5] = 'U'
+	if err := Unmarshal(kMarshalled, &asStruct); !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, uuid, asStruct.U)
+
+	genericSubtype, err := Marshal(map[string]any{"U": uuid[:]})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotNil(t, Unmarshal(genericSubtype, &asStruct))
+}
+
+func TestUUIDWrongSize(t *testing.T) {
+	kMarshalled := []byte{
+		0x0f, 0x00, 0x00, 0x00, // total document size
+		0x05, // etype-binary
+		'u', 0x00,
+		0x02, 0x00, 0x00, 0x00, // size
+		0x04, // subtype (uuid)
+		0xf8, 0x1d,
+		0x00,
+	}
+
+	asMap := make(map[string]any)
+	assert.NotNil(t, Unmarshal(kMarshalled, &asMap))
+
+	_, err := Marshal(map[string]any{"u": Binary{Subtype: BinarySubtypeUUID, Data: []byte{0xf8, 0x1d}}})
+	assert.NotNil(t, err)
+}